@@ -0,0 +1,361 @@
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Linkage defines how the distance between two clusters is computed from
+// distances between their rows.
+type Linkage int
+
+const (
+	// LinkageSingle uses the distance of the closest pair of rows.
+	LinkageSingle Linkage = iota
+	// LinkageComplete uses the distance of the farthest pair of rows.
+	LinkageComplete
+	// LinkageAverage uses the mean distance of all pairs of rows (UPGMA).
+	LinkageAverage
+	// LinkageWard merges clusters with the smallest increase of the sum of
+	// squared distances to cluster centers. It applies the Lance-Williams
+	// update of Ward's method to squared distances, which is exact for
+	// Euclidean distances and Ward-like for the others.
+	LinkageWard
+)
+
+// Merge is a step of agglomerative clustering joining two clusters. Rows are
+// clusters 0, ..., n-1, the cluster created by the i-th merge is n+i, as in
+// scipy's linkage matrix.
+type Merge struct {
+	A, B   int     // joined clusters, A < B
+	Height float64 // linkage distance of joined clusters
+	Size   int     // number of rows of the new cluster
+}
+
+// Agglomerative is a basic class for hierarchical agglomerative clustering.
+// Fitting builds the whole dendrogram, which may be cut into any number of
+// clusters later.
+type Agglomerative struct {
+	DistanceFunc   DistanceFunction
+	Linkage        Linkage
+	ClustersNumber int     // number of clusters of Labels
+	Merges         []Merge // dendrogram, n-1 merges ordered by height
+	LabelsCounter  []int
+	Labels         *DenseVector
+	IsFitted       bool
+}
+
+// NewAgglomerative implements constructor for the Agglomerative struct.
+func NewAgglomerative(dist DistanceFunction, linkage Linkage, clusters int) *Agglomerative {
+	return &Agglomerative{
+		DistanceFunc:   dist,
+		Linkage:        linkage,
+		ClustersNumber: clusters,
+	}
+}
+
+// FitModel builds the dendrogram of rows of X using DistanceFunc and sets
+// Labels to ClustersNumber clusters.
+func (ag *Agglomerative) FitModel(X *DenseMatrix) error {
+	if ag.DistanceFunc == nil {
+		return errors.New("agglomerative: distance function is not set")
+	}
+	D, err := ComputeDistanceMatrix(X, ag.DistanceFunc, 1)
+	if err != nil {
+		return fmt.Errorf("agglomerative: %v", err)
+	}
+	return ag.FitDistances(D)
+}
+
+// FitDistances builds the dendrogram of the dataset described by distance
+// matrix D and sets Labels to ClustersNumber clusters. Merges are found with
+// the nearest-neighbor chain algorithm in O(n^2) time.
+func (ag *Agglomerative) FitDistances(D DistanceMatrix) error {
+	n := D.Len()
+	if ag.ClustersNumber < 1 || ag.ClustersNumber > n {
+		return fmt.Errorf("agglomerative: cannot find %d clusters among %d rows", ag.ClustersNumber, n)
+	}
+	if ag.Linkage < LinkageSingle || ag.Linkage > LinkageWard {
+		return fmt.Errorf("agglomerative: unknown linkage %d", ag.Linkage)
+	}
+	ag.IsFitted = false
+
+	d := make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			v := D.Distance(i, j)
+			if ag.Linkage == LinkageWard {
+				v *= v
+			}
+			d[i*n+j], d[j*n+i] = v, v
+		}
+	}
+	sizes := make([]int, n)
+	active := make([]bool, n)
+	for i := range sizes {
+		sizes[i], active[i] = 1, true
+	}
+
+	// Merges of slots, the joined cluster is kept in the slot b.
+	type slotMerge struct {
+		a, b   int
+		height float64
+	}
+	merges := make([]slotMerge, 0, n-1)
+	heights := make([]float64, n) // height of the last merge of the slot
+	chain := make([]int, 0, n)
+	for len(merges) < n-1 {
+		if len(chain) == 0 {
+			for i, ok := range active {
+				if ok {
+					chain = append(chain, i)
+					break
+				}
+			}
+		}
+		a := chain[len(chain)-1]
+		// Previous cluster of the chain wins ties, so the chain cannot cycle.
+		b, best := -1, math.Inf(1)
+		if len(chain) > 1 {
+			b = chain[len(chain)-2]
+			best = d[a*n+b]
+		}
+		for k, ok := range active {
+			if ok && k != a && d[a*n+k] < best {
+				b, best = k, d[a*n+k]
+			}
+		}
+		if len(chain) < 2 || b != chain[len(chain)-2] {
+			chain = append(chain, b)
+			continue
+		}
+		chain = chain[:len(chain)-2]
+
+		// Lance-Williams updates of average and Ward linkages may round the
+		// distance below the height of a child merge, the merge is kept above
+		// its children so that sorting cannot put it before them.
+		height := math.Max(best, math.Max(heights[a], heights[b]))
+		heights[b] = height
+		merges = append(merges, slotMerge{a: a, b: b, height: height})
+		for k, ok := range active {
+			if !ok || k == a || k == b {
+				continue
+			}
+			v := ag.update(d[a*n+k], d[b*n+k], best, sizes[a], sizes[b], sizes[k])
+			d[b*n+k], d[k*n+b] = v, v
+		}
+		active[a] = false
+		sizes[b] += sizes[a]
+	}
+
+	// The chain finds merges out of order, linkages are reducible, so sorting
+	// them by height gives a valid dendrogram.
+	sort.SliceStable(merges, func(i, j int) bool {
+		return merges[i].height < merges[j].height
+	})
+	ids := make([]int, n) // cluster id of the slot
+	sizes = sizes[:0]
+	for i := 0; i < n; i++ {
+		ids[i] = i
+		sizes = append(sizes, 1)
+	}
+	ag.Merges = make([]Merge, len(merges))
+	for i, m := range merges {
+		a, b := ids[m.a], ids[m.b]
+		if a > b {
+			a, b = b, a
+		}
+		height := m.height
+		if ag.Linkage == LinkageWard {
+			height = math.Sqrt(height)
+		}
+		ag.Merges[i] = Merge{A: a, B: b, Height: height, Size: sizes[a] + sizes[b]}
+		sizes = append(sizes, sizes[a]+sizes[b])
+		ids[m.b] = n + i
+	}
+
+	var err error
+	ag.Labels, err = ag.CutClusters(ag.ClustersNumber)
+	if err != nil {
+		return err
+	}
+	ag.LabelsCounter = make([]int, ag.ClustersNumber)
+	for i := 0; i < n; i++ {
+		ag.LabelsCounter[int(ag.Labels.At(i, 0))]++
+	}
+	ag.IsFitted = true
+	return nil
+}
+
+// update computes with the Lance-Williams formula the distance between the
+// cluster k and the union of clusters a and b, which are at distance dab.
+func (ag *Agglomerative) update(dak, dbk, dab float64, na, nb, nk int) float64 {
+	switch ag.Linkage {
+	case LinkageSingle:
+		return math.Min(dak, dbk)
+	case LinkageComplete:
+		return math.Max(dak, dbk)
+	case LinkageAverage:
+		return (float64(na)*dak + float64(nb)*dbk) / float64(na+nb)
+	default:
+		return (float64(na+nk)*dak + float64(nb+nk)*dbk - float64(nk)*dab) / float64(na+nb+nk)
+	}
+}
+
+// CutClusters cuts the dendrogram into k clusters. Clusters are labelled in
+// the order of their first rows.
+func (ag *Agglomerative) CutClusters(k int) (*DenseVector, error) {
+	if ag.Merges == nil {
+		return nil, errors.New("agglomerative: cannot cut, model is not fitted yet")
+	}
+	n := len(ag.Merges) + 1
+	if k < 1 || k > n {
+		return nil, fmt.Errorf("agglomerative: cannot cut %d rows into %d clusters", n, k)
+	}
+	return ag.cut(n - k), nil
+}
+
+// CutHeight cuts the dendrogram at the given height, rows are in the same
+// cluster if they are joined by merges not higher than height. Clusters are
+// labelled in the order of their first rows.
+func (ag *Agglomerative) CutHeight(height float64) (*DenseVector, error) {
+	if ag.Merges == nil {
+		return nil, errors.New("agglomerative: cannot cut, model is not fitted yet")
+	}
+	merges := sort.Search(len(ag.Merges), func(i int) bool {
+		return ag.Merges[i].Height > height
+	})
+	return ag.cut(merges), nil
+}
+
+// cut labels rows after the given number of merges.
+func (ag *Agglomerative) cut(merges int) *DenseVector {
+	n := len(ag.Merges) + 1
+	// Union-find over clusters of the dendrogram.
+	parent := make([]int, n+merges)
+	for i := range parent {
+		parent[i] = i
+	}
+	find := func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	for i, m := range ag.Merges[:merges] {
+		parent[find(m.A)] = n + i
+		parent[find(m.B)] = n + i
+	}
+
+	labels := NewDenseVector(n, nil)
+	ids := make(map[int]int)
+	for i := 0; i < n; i++ {
+		root := find(i)
+		label, ok := ids[root]
+		if !ok {
+			label = len(ids)
+			ids[root] = label
+		}
+		labels.SetVec(i, float64(label))
+	}
+	return labels
+}
+
+// DendrogramNode is a node of the dendrogram tree. Leaves are rows.
+type DendrogramNode struct {
+	ID       int               `json:"id"`             // row index for leaves, n+i for the node created by the i-th merge
+	Name     string            `json:"name,omitempty"` // name of the row for leaves
+	Height   float64           `json:"height"`
+	Size     int               `json:"size"`
+	Children []*DendrogramNode `json:"children,omitempty"`
+}
+
+// Dendrogram returns the root of the dendrogram tree. names are names of
+// rows, if nil leaves have no names.
+func (ag *Agglomerative) Dendrogram(names []string) (*DendrogramNode, error) {
+	if ag.Merges == nil {
+		return nil, errors.New("agglomerative: model is not fitted yet")
+	}
+	n := len(ag.Merges) + 1
+	if names != nil && len(names) != n {
+		return nil, fmt.Errorf("agglomerative: got %d names for %d rows", len(names), n)
+	}
+	nodes := make([]*DendrogramNode, n, 2*n-1)
+	for i := range nodes {
+		nodes[i] = &DendrogramNode{ID: i, Size: 1}
+		if names != nil {
+			nodes[i].Name = names[i]
+		}
+	}
+	for i, m := range ag.Merges {
+		nodes = append(nodes, &DendrogramNode{
+			ID:       n + i,
+			Height:   m.Height,
+			Size:     m.Size,
+			Children: []*DendrogramNode{nodes[m.A], nodes[m.B]},
+		})
+	}
+	return nodes[len(nodes)-1], nil
+}
+
+// WriteJSON writes the dendrogram tree as JSON, nodes have fields id, name
+// (leaves only), height, size and children.
+func (ag *Agglomerative) WriteJSON(w io.Writer, names []string) error {
+	root, err := ag.Dendrogram(names)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(root)
+}
+
+// WriteNewick writes the dendrogram in the Newick format. Leaves are named by
+// names, or by row indexes if names is nil. Branch lengths are differences of
+// heights of nodes.
+func (ag *Agglomerative) WriteNewick(w io.Writer, names []string) error {
+	root, err := ag.Dendrogram(names)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	writeNewick(&b, root)
+	b.WriteString(";\n")
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+func writeNewick(b *strings.Builder, node *DendrogramNode) {
+	if node.Children == nil {
+		name := node.Name
+		if name == "" {
+			name = strconv.Itoa(node.ID)
+		}
+		b.WriteString(newickName(name))
+		return
+	}
+	b.WriteByte('(')
+	for i, child := range node.Children {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		writeNewick(b, child)
+		b.WriteByte(':')
+		b.WriteString(strconv.FormatFloat(node.Height-child.Height, 'g', -1, 64))
+	}
+	b.WriteByte(')')
+}
+
+// newickName quotes name if it contains characters with special meaning in
+// Newick.
+func newickName(name string) string {
+	if !strings.ContainsAny(name, " ()[]':;,") {
+		return name
+	}
+	return "'" + strings.ReplaceAll(name, "'", "''") + "'"
+}
@@ -0,0 +1,184 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// lineDistances returns distances between points on a line.
+func lineDistances(points ...float64) *FullDistanceMatrix {
+	D, _ := NewFullDistanceMatrix(len(points), nil)
+	for i := range points {
+		for j := range points {
+			D.Set(i, j, math.Abs(points[i]-points[j]))
+		}
+	}
+	return D
+}
+
+func TestAgglomerative_FitDistances(t *testing.T) {
+	D := lineDistances(0, 1, 3, 7)
+	tests := []struct {
+		linkage Linkage
+		want    []Merge
+	}{
+		{LinkageSingle, []Merge{{0, 1, 1, 2}, {2, 4, 2, 3}, {3, 5, 4, 4}}},
+		{LinkageComplete, []Merge{{0, 1, 1, 2}, {2, 4, 3, 3}, {3, 5, 7, 4}}},
+		{LinkageAverage, []Merge{{0, 1, 1, 2}, {2, 4, 2.5, 3}, {3, 5, 17.0 / 3, 4}}},
+		{LinkageWard, []Merge{{0, 1, 1, 2}, {2, 4, math.Sqrt(25.0 / 3), 3}, {3, 5, math.Sqrt(578.0 / 12), 4}}},
+	}
+	for _, tt := range tests {
+		ag := NewAgglomerative(nil, tt.linkage, 2)
+		if err := ag.FitDistances(D); err != nil {
+			t.Fatal(err)
+		}
+		for i, m := range ag.Merges {
+			w := tt.want[i]
+			if m.A != w.A || m.B != w.B || m.Size != w.Size || math.Abs(m.Height-w.Height) > 1e-12 {
+				t.Errorf("linkage %d: merge %d = %v, want %v", tt.linkage, i, m, w)
+			}
+		}
+		if want := []float64{0, 0, 0, 1}; !reflect.DeepEqual(ag.Labels.RawVector().Data, want) {
+			t.Errorf("linkage %d: labels = %v, want %v", tt.linkage, ag.Labels.RawVector().Data, want)
+		}
+		if want := []int{3, 1}; !reflect.DeepEqual(ag.LabelsCounter, want) || !ag.IsFitted {
+			t.Errorf("linkage %d: labels counter = %v, want %v", tt.linkage, ag.LabelsCounter, want)
+		}
+	}
+}
+
+func TestAgglomerative_Ties(t *testing.T) {
+	D, _ := NewCondensedDistanceMatrix(5, []float64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1})
+	ag := NewAgglomerative(nil, LinkageAverage, 1)
+	if err := ag.FitDistances(D); err != nil {
+		t.Fatal(err)
+	}
+	if len(ag.Merges) != 4 || ag.Merges[3].Size != 5 {
+		t.Errorf("merges = %v", ag.Merges)
+	}
+}
+
+func TestAgglomerative_Rounding(t *testing.T) {
+	// The average of the last merge, (2*0.7+0.7)/3, is rounded below 0.7.
+	D, _ := NewCondensedDistanceMatrix(4, []float64{0.7, 0.7, 0.1, 0.7, 0.7, 0.7})
+	ag := NewAgglomerative(nil, LinkageAverage, 2)
+	if err := ag.FitDistances(D); err != nil {
+		t.Fatal(err)
+	}
+	want := []Merge{{0, 3, 0.1, 2}, {1, 4, 0.7, 3}, {2, 5, 0.7, 4}}
+	if !reflect.DeepEqual(ag.Merges, want) {
+		t.Errorf("merges = %v, want %v", ag.Merges, want)
+	}
+	if want := []float64{0, 0, 1, 0}; !reflect.DeepEqual(ag.Labels.RawVector().Data, want) {
+		t.Errorf("labels = %v, want %v", ag.Labels.RawVector().Data, want)
+	}
+}
+
+func TestAgglomerative_Cut(t *testing.T) {
+	ag := NewAgglomerative(nil, LinkageSingle, 1)
+	if _, err := ag.CutClusters(2); err == nil {
+		t.Error("CutClusters() of not fitted model, want error")
+	}
+	if err := ag.FitDistances(lineDistances(7, 0, 1, 3)); err != nil {
+		t.Fatal(err)
+	}
+
+	byClusters := []struct {
+		k    int
+		want []float64
+	}{
+		{1, []float64{0, 0, 0, 0}},
+		{2, []float64{0, 1, 1, 1}},
+		{3, []float64{0, 1, 1, 2}},
+		{4, []float64{0, 1, 2, 3}},
+	}
+	for _, tt := range byClusters {
+		got, err := ag.CutClusters(tt.k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got.RawVector().Data, tt.want) {
+			t.Errorf("CutClusters(%d) = %v, want %v", tt.k, got.RawVector().Data, tt.want)
+		}
+	}
+	for _, k := range []int{0, 5} {
+		if _, err := ag.CutClusters(k); err == nil {
+			t.Errorf("CutClusters(%d), want error", k)
+		}
+	}
+
+	byHeight := []struct {
+		height float64
+		want   []float64
+	}{
+		{0, []float64{0, 1, 2, 3}},
+		{1, []float64{0, 1, 1, 2}},
+		{3.9, []float64{0, 1, 1, 1}},
+		{4, []float64{0, 0, 0, 0}},
+	}
+	for _, tt := range byHeight {
+		got, _ := ag.CutHeight(tt.height)
+		if !reflect.DeepEqual(got.RawVector().Data, tt.want) {
+			t.Errorf("CutHeight(%v) = %v, want %v", tt.height, got.RawVector().Data, tt.want)
+		}
+	}
+}
+
+func TestAgglomerative_FitModel(t *testing.T) {
+	X := NewDenseMatrix(6, 3, []float64{
+		0, 0, 0,
+		1, 1, 1,
+		0, 0, 1,
+		1, 1, 0,
+		0, 0, 2,
+		1, 1, 2,
+	})
+	ag := NewAgglomerative(HammingDistance, LinkageComplete, 2)
+	if err := ag.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{0, 1, 0, 1, 0, 1}; !reflect.DeepEqual(ag.Labels.RawVector().Data, want) {
+		t.Errorf("labels = %v, want %v", ag.Labels.RawVector().Data, want)
+	}
+	if err := NewAgglomerative(HammingDistance, LinkageComplete, 7).FitModel(X); err == nil {
+		t.Error("FitModel() with more clusters than rows, want error")
+	}
+}
+
+func TestAgglomerative_Export(t *testing.T) {
+	ag := NewAgglomerative(nil, LinkageSingle, 1)
+	if err := ag.FitDistances(lineDistances(0, 1, 3, 7)); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := ag.WriteNewick(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := "(3:4,(2:2,(0:1,1:1):1):2);\n"; buf.String() != want {
+		t.Errorf("WriteNewick() = %q, want %q", buf.String(), want)
+	}
+	buf.Reset()
+	ag.WriteNewick(&buf, []string{"a", "b", "c", "it's d"})
+	if want := "('it''s d':4,(c:2,(a:1,b:1):1):2);\n"; buf.String() != want {
+		t.Errorf("WriteNewick() = %q, want %q", buf.String(), want)
+	}
+	if err := ag.WriteNewick(&buf, []string{"a"}); err == nil {
+		t.Error("WriteNewick() with too few names, want error")
+	}
+
+	buf.Reset()
+	if err := ag.WriteJSON(&buf, []string{"a", "b", "c", "d"}); err != nil {
+		t.Fatal(err)
+	}
+	var root DendrogramNode
+	if err := json.Unmarshal(buf.Bytes(), &root); err != nil {
+		t.Fatal(err)
+	}
+	if root.ID != 6 || root.Size != 4 || root.Height != 4 || len(root.Children) != 2 || root.Children[0].Name != "d" || root.Children[1].ID != 5 {
+		t.Errorf("WriteJSON() = %s", buf.String())
+	}
+}
@@ -0,0 +1,66 @@
+package cluster
+
+import (
+	"errors"
+	"math"
+)
+
+// Margins computes for every row of distances returned by Transform the
+// difference between distances to the second nearest and to the nearest
+// cluster. Small margin means that the row lies on the border of two clusters.
+// If a row has less than two finite distances, its margin is +Inf.
+func Margins(distances *DenseMatrix) *DenseVector {
+	xRows, _ := distances.Dims()
+	margins := NewDenseVector(xRows, nil)
+	for i := 0; i < xRows; i++ {
+		best, second := math.Inf(1), math.Inf(1)
+		for _, d := range distances.RawRowView(i) {
+			if d < best {
+				best, second = d, best
+			} else if d < second {
+				second = d
+			}
+		}
+		if math.IsInf(second, 1) {
+			margins.SetVec(i, second)
+			continue
+		}
+		margins.SetVec(i, second-best)
+	}
+	return margins
+}
+
+// SoftAssignments converts distances returned by Transform to probabilities of
+// cluster membership with softmax of negative distances divided by
+// temperature: p(c) = exp(-d(c)/T) / sum(exp(-d(k)/T)). Lower temperature
+// gives sharper assignments. Clusters with infinite distance (dropped ones)
+// get probability 0.
+func SoftAssignments(distances *DenseMatrix, temperature float64) (*DenseMatrix, error) {
+	if temperature <= 0 {
+		return nil, errors.New("soft assignments: temperature must be positive")
+	}
+	xRows, clusters := distances.Dims()
+	probabilities := NewDenseMatrix(xRows, clusters, nil)
+	for i := 0; i < xRows; i++ {
+		row := distances.RawRowView(i)
+		best := math.Inf(1)
+		for _, d := range row {
+			best = math.Min(best, d)
+		}
+		if math.IsInf(best, 1) {
+			continue
+		}
+
+		// Shift by the smallest distance to avoid underflow of all terms.
+		p := probabilities.RawRowView(i)
+		var sum float64
+		for c, d := range row {
+			p[c] = math.Exp(-(d - best) / temperature)
+			sum += p[c]
+		}
+		for c := range p {
+			p[c] /= sum
+		}
+	}
+	return probabilities, nil
+}
@@ -0,0 +1,97 @@
+package cluster
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMargins(t *testing.T) {
+	inf := math.Inf(1)
+	distances := NewDenseMatrix(4, 3, []float64{
+		1, 3, 2,
+		2, 2, 5,
+		4, inf, inf,
+		inf, inf, inf,
+	})
+	want := []float64{1, 0, inf, inf}
+	got := Margins(distances)
+	for i, w := range want {
+		if g := got.At(i, 0); g != w {
+			t.Errorf("Margins() row %d = %v, want %v", i, g, w)
+		}
+	}
+}
+
+func TestSoftAssignments(t *testing.T) {
+	inf := math.Inf(1)
+	distances := NewDenseMatrix(3, 3, []float64{
+		1, 2, inf,
+		1000, 1000, 1001,
+		inf, inf, inf,
+	})
+	tests := []struct {
+		temperature float64
+		want        []float64
+		wantErr     bool
+	}{
+		{temperature: 1, want: []float64{
+			1 / (1 + math.Exp(-1)), math.Exp(-1) / (1 + math.Exp(-1)), 0,
+			1 / (2 + math.Exp(-1)), 1 / (2 + math.Exp(-1)), math.Exp(-1) / (2 + math.Exp(-1)),
+			0, 0, 0,
+		}},
+		{temperature: 0.5, want: []float64{
+			1 / (1 + math.Exp(-2)), math.Exp(-2) / (1 + math.Exp(-2)), 0,
+			1 / (2 + math.Exp(-2)), 1 / (2 + math.Exp(-2)), math.Exp(-2) / (2 + math.Exp(-2)),
+			0, 0, 0,
+		}},
+		{temperature: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := SoftAssignments(distances, tt.temperature)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SoftAssignments(%v) error = %v, wantErr %v", tt.temperature, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		for i, w := range tt.want {
+			if g := got.RawMatrix().Data[i]; math.Abs(g-w) > 1e-12 {
+				t.Errorf("SoftAssignments(%v) element %d = %v, want %v", tt.temperature, i, g, w)
+			}
+		}
+	}
+}
+
+func TestKModes_TransformMargins(t *testing.T) {
+	X := randomCategorical(100, 6, 3, 5)
+	km := NewKModes(HammingDistance, InitHuang, 3, 1, 20, [][]float64{{1}}, "")
+	km.Seed = 1
+	if err := km.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+	distances, err := km.Transform(X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	probabilities, _ := SoftAssignments(distances, 1)
+	margins := Margins(distances)
+	labels, _ := km.Predict(X)
+	for i := 0; i < 100; i++ {
+		label := int(labels.At(i, 0))
+		var sum float64
+		for c := 0; c < 3; c++ {
+			sum += probabilities.At(i, c)
+			if probabilities.At(i, c) > probabilities.At(i, label) {
+				t.Errorf("row %d: cluster %d is more probable than predicted %d", i, c, label)
+			}
+		}
+		if math.Abs(sum-1) > 1e-12 {
+			t.Errorf("row %d: probabilities sum to %v", i, sum)
+		}
+		// Rows on the border have equal distances to two clusters.
+		if m := margins.At(i, 0); m < 0 || (m == 0 && probabilities.At(i, label) > 0.5) {
+			t.Errorf("row %d: margin %v, probability %v", i, m, probabilities.At(i, label))
+		}
+	}
+}
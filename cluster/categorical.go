@@ -0,0 +1,138 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// CategoricalMatrix stores dictionary-encoded categorical dataset in compact
+// form. Values of every column are stored as uint8, uint16 or uint32 codes,
+// the smallest type which fits the column cardinality is chosen. Values must
+// be integers in range [0, cardinality).
+type CategoricalMatrix struct {
+	rows, cols int
+	columns    []categoricalColumn
+}
+
+// categoricalColumn holds codes of one column, only one of slices is used.
+type categoricalColumn struct {
+	codes8  []uint8
+	codes16 []uint16
+	codes32 []uint32
+}
+
+// NewCategoricalMatrix creates new CategoricalMatrix with r rows, cardinalities
+// define number of distinct values of every column. All values are set to 0.
+func NewCategoricalMatrix(r int, cardinalities []int) *CategoricalMatrix {
+	m := &CategoricalMatrix{rows: r, cols: len(cardinalities), columns: make([]categoricalColumn, len(cardinalities))}
+	for j, card := range cardinalities {
+		switch {
+		case card <= math.MaxUint8+1:
+			m.columns[j].codes8 = make([]uint8, r)
+		case card <= math.MaxUint16+1:
+			m.columns[j].codes16 = make([]uint16, r)
+		default:
+			m.columns[j].codes32 = make([]uint32, r)
+		}
+	}
+	return m
+}
+
+// NewCategoricalMatrixFromDense creates CategoricalMatrix with values of X,
+// cardinality of each column is derived from its maximum value.
+func NewCategoricalMatrixFromDense(X *DenseMatrix) (*CategoricalMatrix, error) {
+	xRows, xCols := X.Dims()
+	cardinalities := make([]int, xCols)
+	for i := 0; i < xRows; i++ {
+		for j, v := range X.RawRowView(i) {
+			if v < 0 || v > math.MaxUint32 || v != math.Trunc(v) {
+				return nil, fmt.Errorf("categorical matrix: value %v at (%d, %d) is not a valid code", v, i, j)
+			}
+			if int(v) >= cardinalities[j] {
+				cardinalities[j] = int(v) + 1
+			}
+		}
+	}
+
+	m := NewCategoricalMatrix(xRows, cardinalities)
+	for i := 0; i < xRows; i++ {
+		for j, v := range X.RawRowView(i) {
+			m.Set(i, j, v)
+		}
+	}
+	return m, nil
+}
+
+// Dims returns the number of rows and columns.
+func (m *CategoricalMatrix) Dims() (r, c int) {
+	return m.rows, m.cols
+}
+
+// At returns the value of element at row i and column j.
+func (m *CategoricalMatrix) At(i, j int) float64 {
+	col := &m.columns[j]
+	switch {
+	case col.codes8 != nil:
+		return float64(col.codes8[i])
+	case col.codes16 != nil:
+		return float64(col.codes16[i])
+	}
+	return float64(col.codes32[i])
+}
+
+// Set sets the value of element at row i and column j. It panics if v does
+// not fit the column type.
+func (m *CategoricalMatrix) Set(i, j int, v float64) {
+	if err := m.checkValue(j, v); err != nil {
+		panic(err)
+	}
+	col := &m.columns[j]
+	switch {
+	case col.codes8 != nil:
+		col.codes8[i] = uint8(v)
+	case col.codes16 != nil:
+		col.codes16[i] = uint16(v)
+	default:
+		col.codes32[i] = uint32(v)
+	}
+}
+
+// RowTo copies values of row i into dst and returns it.
+func (m *CategoricalMatrix) RowTo(dst []float64, i int) []float64 {
+	for j := range m.columns {
+		dst[j] = m.At(i, j)
+	}
+	return dst
+}
+
+// rowBytes returns the number of bytes used to store one row.
+func (m *CategoricalMatrix) rowBytes() int {
+	var size int
+	for _, col := range m.columns {
+		switch {
+		case col.codes8 != nil:
+			size++
+		case col.codes16 != nil:
+			size += 2
+		default:
+			size += 4
+		}
+	}
+	return size
+}
+
+func (m *CategoricalMatrix) checkValue(j int, v float64) error {
+	col := &m.columns[j]
+	max := float64(math.MaxUint32)
+	switch {
+	case col.codes8 != nil:
+		max = math.MaxUint8
+	case col.codes16 != nil:
+		max = math.MaxUint16
+	}
+	if v < 0 || v > max || v != math.Trunc(v) {
+		return errors.New("categorical matrix: value out of range of column codes")
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package cluster
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestNewCategoricalMatrix(t *testing.T) {
+	m := NewCategoricalMatrix(3, []int{2, 256, 257, 70000})
+	if m.columns[0].codes8 == nil || m.columns[1].codes8 == nil || m.columns[2].codes16 == nil || m.columns[3].codes32 == nil {
+		t.Errorf("NewCategoricalMatrix() chose wrong column types")
+	}
+	if got := m.rowBytes(); got != 8 {
+		t.Errorf("CategoricalMatrix.rowBytes() = %v, want 8", got)
+	}
+
+	m.Set(2, 0, 1)
+	m.Set(2, 1, 255)
+	m.Set(2, 2, 256)
+	m.Set(2, 3, 69999)
+	if got := m.RowTo(make([]float64, 4), 2); !reflect.DeepEqual(got, []float64{1, 255, 256, 69999}) {
+		t.Errorf("CategoricalMatrix.RowTo() = %v", got)
+	}
+	if r, c := m.Dims(); r != 3 || c != 4 {
+		t.Errorf("CategoricalMatrix.Dims() = %d, %d", r, c)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("CategoricalMatrix.Set() did not panic on value out of range")
+		}
+	}()
+	m.Set(0, 1, 256)
+}
+
+func TestNewCategoricalMatrixFromDense(t *testing.T) {
+	tests := []struct {
+		X       *DenseMatrix
+		wantErr bool
+	}{
+		{X: NewDenseMatrix(2, 2, []float64{1, 2, 3, 300}), wantErr: false},
+		{X: NewDenseMatrix(2, 2, []float64{1, 2, 3, -1}), wantErr: true},
+		{X: NewDenseMatrix(2, 2, []float64{1, 2.5, 3, 4}), wantErr: true},
+	}
+	for i, tt := range tests {
+		got, err := NewCategoricalMatrixFromDense(tt.X)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%d. NewCategoricalMatrixFromDense() error = %v, wantErr %v", i, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		for r := 0; r < 2; r++ {
+			for c := 0; c < 2; c++ {
+				if got.At(r, c) != tt.X.At(r, c) {
+					t.Errorf("%d. CategoricalMatrix.At(%d, %d) = %v, want %v", i, r, c, got.At(r, c), tt.X.At(r, c))
+				}
+			}
+		}
+	}
+}
+
+func TestKModes_FitModelCategoricalMatrix(t *testing.T) {
+	X := randomCategorical(200, 6, 4, 3)
+	C, err := NewCategoricalMatrixFromDense(X)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(CreateFrequencyTable(X), CreateFrequencyTable(C)) {
+		t.Errorf("CreateFrequencyTable() differs for CategoricalMatrix")
+	}
+
+	for _, init := range []InitializationFunction{InitCao, InitHuang, InitRandom} {
+		want, _ := init(X, 4, HammingDistance, rand.New(rand.NewSource(1)))
+		got, _ := init(C, 4, HammingDistance, rand.New(rand.NewSource(1)))
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("initialization with CategoricalMatrix = %v, want %v", got.RawMatrix().Data, want.RawMatrix().Data)
+		}
+	}
+
+	for _, dist := range []DistanceFunction{HammingDistance, unpackedHamming} {
+		km1 := NewKModes(dist, InitHuang, 4, 1, 20, [][]float64{{1}}, "")
+		km1.Seed = 1
+		km2 := NewKModes(dist, InitHuang, 4, 1, 20, [][]float64{{1}}, "")
+		km2.Seed = 1
+		if err := km1.FitModel(X); err != nil {
+			t.Fatal(err)
+		}
+		if err := km2.FitModel(C); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(km1.Labels, km2.Labels) || !reflect.DeepEqual(km1.ClusterCentroids, km2.ClusterCentroids) {
+			t.Errorf("KModes.FitModel() with CategoricalMatrix differs from DenseMatrix")
+		}
+
+		got, err := km2.Predict(C)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, km1.Labels) {
+			t.Errorf("KModes.Predict() with CategoricalMatrix = %v, want %v", got.RawVector().Data, km1.Labels.RawVector().Data)
+		}
+	}
+}
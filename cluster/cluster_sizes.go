@@ -0,0 +1,146 @@
+package cluster
+
+import (
+	"container/heap"
+	"fmt"
+)
+
+// checkClusterSizes validates limits of cluster sizes for a dataset with the
+// given number of rows, max 0 means no upper limit.
+func checkClusterSizes(min, max, clusters, rows int) error {
+	if min < 0 || max < 0 || (max > 0 && max < min) {
+		return fmt.Errorf("wrong cluster size limits [%d, %d]", min, max)
+	}
+	if min*clusters > rows || (max > 0 && max*clusters < rows) {
+		return fmt.Errorf("cannot split %d rows into %d clusters of %d to %d rows", rows, clusters, min, max)
+	}
+	return nil
+}
+
+// nearestCluster returns the first cluster with the smallest distance.
+func nearestCluster(distances []float64) int {
+	nearest := 0
+	for c, d := range distances {
+		if d < distances[nearest] {
+			nearest = c
+		}
+	}
+	return nearest
+}
+
+// transfer is a candidate move of row to cluster, cost is the increase of the
+// distance of the row to its centroid.
+type transfer struct {
+	cost         float64
+	row, cluster int
+}
+
+// transferHeap orders transfers from the cheapest one, ties are resolved by
+// row and cluster so that results are reproducible.
+type transferHeap []transfer
+
+func (h transferHeap) Len() int { return len(h) }
+func (h transferHeap) Less(i, j int) bool {
+	if h[i].cost != h[j].cost {
+		return h[i].cost < h[j].cost
+	}
+	if h[i].row != h[j].row {
+		return h[i].row < h[j].row
+	}
+	return h[i].cluster < h[j].cluster
+}
+func (h transferHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *transferHeap) Push(x interface{}) { *h = append(*h, x.(transfer)) }
+func (h *transferHeap) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return t
+}
+
+// balanceLabels moves rows between clusters until every cluster, except
+// dropped ones, has between min and max rows (max 0 means no upper limit).
+// labels are nearest clusters of rows, distances of row i to clusters are in
+// distances[i*k:(i+1)*k]. Rows are moved greedily, always the one whose
+// distance to its centroid increases least: first out of clusters with more
+// than max rows, then into clusters with less than min rows.
+func balanceLabels(labels []int, distances []float64, k int, dropped []int, min, max int) {
+	counts := make([]int, k)
+	for _, l := range labels {
+		counts[l]++
+	}
+	active := func(c int) bool {
+		return !isDropped(dropped, c)
+	}
+
+	if max > 0 {
+		// bestTarget finds the nearest cluster other than c with room for
+		// row i.
+		bestTarget := func(i, c int) (transfer, bool) {
+			best := transfer{row: i, cluster: -1}
+			for t := 0; t < k; t++ {
+				if t == c || !active(t) || counts[t] >= max {
+					continue
+				}
+				cost := distances[i*k+t] - distances[i*k+c]
+				if best.cluster < 0 || cost < best.cost {
+					best.cost, best.cluster = cost, t
+				}
+			}
+			return best, best.cluster >= 0
+		}
+		for c := 0; c < k; c++ {
+			if counts[c] <= max {
+				continue
+			}
+			h := &transferHeap{}
+			for i, l := range labels {
+				if l != c {
+					continue
+				}
+				if t, ok := bestTarget(i, c); ok {
+					*h = append(*h, t)
+				}
+			}
+			heap.Init(h)
+			for counts[c] > max && h.Len() > 0 {
+				t := heap.Pop(h).(transfer)
+				if counts[t.cluster] >= max {
+					// The target got full, try the next nearest one.
+					if next, ok := bestTarget(t.row, c); ok {
+						heap.Push(h, next)
+					}
+					continue
+				}
+				labels[t.row] = t.cluster
+				counts[c]--
+				counts[t.cluster]++
+			}
+		}
+	}
+
+	if min > 0 {
+		for c := 0; c < k; c++ {
+			if !active(c) || counts[c] >= min {
+				continue
+			}
+			h := &transferHeap{}
+			for i, l := range labels {
+				if l != c && counts[l] > min {
+					*h = append(*h, transfer{cost: distances[i*k+c] - distances[i*k+l], row: i, cluster: c})
+				}
+			}
+			heap.Init(h)
+			for counts[c] < min && h.Len() > 0 {
+				t := heap.Pop(h).(transfer)
+				source := labels[t.row]
+				if counts[source] <= min {
+					continue
+				}
+				labels[t.row] = c
+				counts[source]--
+				counts[c]++
+			}
+		}
+	}
+}
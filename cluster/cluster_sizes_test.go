@@ -0,0 +1,135 @@
+package cluster
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckClusterSizes(t *testing.T) {
+	tests := []struct {
+		min, max int
+		wantErr  bool
+	}{
+		{0, 0, false},
+		{2, 0, false},
+		{2, 4, false},
+		{0, 4, false},
+		{3, 2, true},
+		{-1, 0, true},
+		{4, 0, true},
+		{0, 2, true},
+	}
+	for i, tt := range tests {
+		if err := checkClusterSizes(tt.min, tt.max, 3, 10); (err != nil) != tt.wantErr {
+			t.Errorf("%d. checkClusterSizes() error = %v, wantErr %v", i, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBalanceLabels(t *testing.T) {
+	// Distances of 6 rows to 3 clusters.
+	distances := []float64{
+		0, 5, 9,
+		1, 2, 9,
+		0, 4, 3,
+		1, 3, 9,
+		2, 2.5, 9,
+		5, 0, 9,
+	}
+	tests := []struct {
+		min, max int
+		dropped  []int
+		want     []int
+	}{
+		{0, 0, nil, []int{0, 0, 0, 0, 0, 1}},
+		{0, 3, nil, []int{0, 1, 0, 0, 1, 1}},
+		{0, 2, nil, []int{0, 2, 2, 0, 1, 1}},
+		{2, 0, nil, []int{0, 2, 2, 0, 1, 1}},
+		{2, 0, []int{2}, []int{0, 0, 0, 0, 1, 1}},
+		{1, 4, nil, []int{0, 0, 2, 0, 1, 1}},
+	}
+	for i, tt := range tests {
+		labels := []int{0, 0, 0, 0, 0, 1}
+		balanceLabels(labels, distances, 3, tt.dropped, tt.min, tt.max)
+		if !reflect.DeepEqual(labels, tt.want) {
+			t.Errorf("%d. balanceLabels() = %v, want %v", i, labels, tt.want)
+		}
+	}
+}
+
+// checkSizes checks that counters match labels and stay within limits.
+func checkSizes(t *testing.T, name string, labels *DenseVector, counter []int, min, max int) {
+	t.Helper()
+	counts := make([]int, len(counter))
+	for i := 0; i < labels.Len(); i++ {
+		counts[int(labels.At(i, 0))]++
+	}
+	if !reflect.DeepEqual(counts, counter) {
+		t.Errorf("%s: LabelsCounter = %v, labels give %v", name, counter, counts)
+	}
+	for c, n := range counts {
+		if n < min || n > max {
+			t.Errorf("%s: cluster %d has %d rows, want [%d, %d]", name, c, n, min, max)
+		}
+	}
+}
+
+func TestKModes_ClusterSizes(t *testing.T) {
+	X := randomCategorical(100, 5, 3, 8)
+	km := NewKModes(HammingDistance, InitHuang, 4, 1, 50, [][]float64{{1}}, "")
+	km.Seed = 2
+	if err := km.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+	checkSizes(t, "unlimited", km.Labels, km.LabelsCounter, 0, 100)
+
+	km.MinClusterSize, km.MaxClusterSize = 20, 30
+	if err := km.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+	if !km.IsFitted {
+		t.Errorf("limited fit stopped with %v", km.StopReason)
+	}
+	checkSizes(t, "limited", km.Labels, km.LabelsCounter, 20, 30)
+	for c, table := range km.FrequencyTable {
+		var members float64
+		for _, n := range table[0] {
+			members += n
+		}
+		if members != float64(km.LabelsCounter[c]) {
+			t.Errorf("cluster %d frequency table has %v rows, want %d", c, members, km.LabelsCounter[c])
+		}
+	}
+
+	km.MinClusterSize = 30
+	if err := km.FitModel(X); err == nil {
+		t.Error("FitModel() with infeasible limits, want error")
+	}
+	km.MinClusterSize, km.CompressRows = 0, true
+	if err := km.FitModel(X); err == nil {
+		t.Error("FitModel() with limits and compressed rows, want error")
+	}
+}
+
+func TestKPrototypes_ClusterSizes(t *testing.T) {
+	X := NewDenseMatrix(10, 2, []float64{
+		0, 0.1,
+		0, 0.2,
+		0, 0.1,
+		0, 0.3,
+		0, 0.2,
+		0, 0.4,
+		0, 0.1,
+		1, 5.2,
+		1, 5.0,
+		1, 5.3,
+	})
+	kp := NewKPrototypes(HammingDistance, nil, []int{0}, 2, 1, 20, [][]float64{{1}}, 1, "")
+	kp.MixedInitFunc = InitCaoMixed
+	kp.Seed = 1
+	kp.MinClusterSize, kp.MaxClusterSize = 4, 6
+	if err := kp.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+	checkSizes(t, "kprototypes", kp.Labels, kp.LabelsCounter, 4, 6)
+}
@@ -0,0 +1,158 @@
+package cluster
+
+import (
+	"fmt"
+	"math"
+)
+
+// Constraints are must-link and cannot-link constraints of constrained
+// (COP-style) clustering. Rows are given by their indexes in the fitted data.
+type Constraints struct {
+	MustLink   [][2]int // pairs of rows which must be in the same cluster
+	CannotLink [][2]int // pairs of rows which must be in different clusters
+}
+
+// Violation is a constraint which is not satisfied by fitted labels.
+type Violation struct {
+	A, B     int  // constrained rows
+	MustLink bool // true for a must-link constraint, false for a cannot-link one
+}
+
+// violations checks all constraints against labels.
+func (c *Constraints) violations(labels *DenseVector) []Violation {
+	if c == nil {
+		return nil
+	}
+	var violations []Violation
+	for _, p := range c.MustLink {
+		if labels.At(p[0], 0) != labels.At(p[1], 0) {
+			violations = append(violations, Violation{A: p[0], B: p[1], MustLink: true})
+		}
+	}
+	for _, p := range c.CannotLink {
+		if labels.At(p[0], 0) == labels.At(p[1], 0) {
+			violations = append(violations, Violation{A: p[0], B: p[1]})
+		}
+	}
+	return violations
+}
+
+// constraintSet holds constraints prepared for the assignment step. Rows
+// connected by must-links form groups, so must-links are transitive, and
+// cannot-links separate whole groups.
+type constraintSet struct {
+	group      []int   // group of every row, -1 for rows without constraints
+	members    [][]int // rows of every group in ascending order
+	cannotLink [][]int // groups which must not share the cluster with the group
+}
+
+// newConstraintSet validates constraints on a dataset with the given number
+// of rows and prepares them for the assignment step.
+func newConstraintSet(c *Constraints, rows int) (*constraintSet, error) {
+	for _, pairs := range [][][2]int{c.MustLink, c.CannotLink} {
+		for _, p := range pairs {
+			if p[0] < 0 || p[0] >= rows || p[1] < 0 || p[1] >= rows || p[0] == p[1] {
+				return nil, fmt.Errorf("wrong constraint of rows %d and %d for %d rows", p[0], p[1], rows)
+			}
+		}
+	}
+
+	// Union-find over rows joined by must-links.
+	parent := make(map[int]int)
+	var find func(i int) int
+	find = func(i int) int {
+		p, ok := parent[i]
+		if !ok {
+			parent[i] = i
+			return i
+		}
+		if p != i {
+			parent[i] = find(p)
+		}
+		return parent[i]
+	}
+	for _, p := range c.MustLink {
+		parent[find(p[0])] = find(p[1])
+	}
+	for _, p := range c.CannotLink {
+		find(p[0])
+		find(p[1])
+	}
+
+	cs := &constraintSet{group: make([]int, rows)}
+	roots := make(map[int]int)
+	for i := range cs.group {
+		cs.group[i] = -1
+		if _, ok := parent[i]; !ok {
+			continue
+		}
+		root := find(i)
+		g, ok := roots[root]
+		if !ok {
+			g = len(cs.members)
+			roots[root] = g
+			cs.members = append(cs.members, nil)
+			cs.cannotLink = append(cs.cannotLink, nil)
+		}
+		cs.group[i] = g
+		cs.members[g] = append(cs.members[g], i)
+	}
+	for _, p := range c.CannotLink {
+		a, b := cs.group[p[0]], cs.group[p[1]]
+		cs.cannotLink[a] = append(cs.cannotLink[a], b)
+		cs.cannotLink[b] = append(cs.cannotLink[b], a)
+	}
+	return cs, nil
+}
+
+// allowed checks whether row i may join the cluster. Rows are assigned in
+// ascending order, so only rows before i, which were already assigned in the
+// current pass, are checked.
+func (cs *constraintSet) allowed(i, cluster int, labels *DenseVector) bool {
+	g := cs.group[i]
+	if g < 0 {
+		return true
+	}
+	for _, m := range cs.members[g] {
+		if m >= i {
+			break
+		}
+		if int(labels.At(m, 0)) != cluster {
+			return false
+		}
+	}
+	for _, other := range cs.cannotLink[g] {
+		for _, m := range cs.members[other] {
+			if m >= i {
+				break
+			}
+			if int(labels.At(m, 0)) == cluster {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// choose returns the nearest cluster row i may join and the distance to it,
+// distances holds distances of the row to clusters, +Inf for dropped ones. If
+// no cluster satisfies constraints, the nearest one is chosen and the
+// violation is reported after fitting.
+func (cs *constraintSet) choose(i int, distances []float64, labels *DenseVector) (int, float64) {
+	best, nearest := -1, -1
+	for c, d := range distances {
+		if math.IsInf(d, 1) {
+			continue
+		}
+		if nearest < 0 || d < distances[nearest] {
+			nearest = c
+		}
+		if (best < 0 || d < distances[best]) && cs.allowed(i, c, labels) {
+			best = c
+		}
+	}
+	if best < 0 {
+		best = nearest
+	}
+	return best, distances[best]
+}
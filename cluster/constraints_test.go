@@ -0,0 +1,129 @@
+package cluster
+
+import (
+	"reflect"
+	"testing"
+)
+
+func twoGroups() *DenseMatrix {
+	return NewDenseMatrix(10, 3, []float64{
+		0, 0, 0,
+		0, 0, 0,
+		0, 0, 1,
+		0, 0, 0,
+		0, 1, 0,
+		1, 1, 1,
+		1, 1, 1,
+		1, 1, 0,
+		1, 1, 1,
+		1, 0, 1,
+	})
+}
+
+func TestNewConstraintSet(t *testing.T) {
+	tests := []struct {
+		c       Constraints
+		wantErr bool
+	}{
+		{Constraints{MustLink: [][2]int{{0, 1}}, CannotLink: [][2]int{{1, 2}}}, false},
+		{Constraints{MustLink: [][2]int{{0, 3}}}, true},
+		{Constraints{CannotLink: [][2]int{{-1, 2}}}, true},
+		{Constraints{MustLink: [][2]int{{1, 1}}}, true},
+	}
+	for i, tt := range tests {
+		if _, err := newConstraintSet(&tt.c, 3); (err != nil) != tt.wantErr {
+			t.Errorf("%d. newConstraintSet() error = %v, wantErr %v", i, err, tt.wantErr)
+		}
+	}
+
+	// Must-links are transitive, cannot-links separate whole groups.
+	cs, _ := newConstraintSet(&Constraints{MustLink: [][2]int{{3, 1}, {1, 0}}, CannotLink: [][2]int{{0, 4}}}, 5)
+	labels := NewDenseVector(5, []float64{2, 2, 0, 0, 0})
+	if cs.allowed(3, 1, labels) || !cs.allowed(3, 2, labels) || !cs.allowed(2, 1, labels) {
+		t.Error("allowed() does not follow must-links")
+	}
+	labels = NewDenseVector(5, []float64{1, 1, 0, 1, 0})
+	if cs.allowed(4, 1, labels) || !cs.allowed(4, 0, labels) {
+		t.Error("allowed() does not follow cannot-links")
+	}
+}
+
+func TestConstraints_violations(t *testing.T) {
+	c := &Constraints{MustLink: [][2]int{{0, 1}, {1, 2}}, CannotLink: [][2]int{{0, 3}, {2, 3}}}
+	got := c.violations(NewDenseVector(4, []float64{0, 0, 1, 1}))
+	want := []Violation{{A: 1, B: 2, MustLink: true}, {A: 2, B: 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("violations() = %v, want %v", got, want)
+	}
+	if got := (*Constraints)(nil).violations(nil); got != nil {
+		t.Errorf("violations() without constraints = %v, want nil", got)
+	}
+}
+
+func TestKModes_Constraints(t *testing.T) {
+	newModel := func(c *Constraints) *KModes {
+		km := NewKModes(HammingDistance, InitCao, 2, 1, 20, [][]float64{{1}}, "")
+		km.Seed = 1
+		km.Constraints = c
+		return km
+	}
+	km := newModel(nil)
+	if err := km.FitModel(twoGroups()); err != nil {
+		t.Fatal(err)
+	}
+	if km.Labels.At(0, 0) != km.Labels.At(1, 0) || km.Labels.At(2, 0) == km.Labels.At(7, 0) {
+		t.Fatalf("unconstrained labels = %v", km.Labels.RawVector().Data)
+	}
+
+	c := &Constraints{MustLink: [][2]int{{2, 7}}, CannotLink: [][2]int{{0, 1}}}
+	km = newModel(c)
+	if err := km.FitModel(twoGroups()); err != nil {
+		t.Fatal(err)
+	}
+	if km.Violations != nil || km.Labels.At(0, 0) == km.Labels.At(1, 0) || km.Labels.At(2, 0) != km.Labels.At(7, 0) {
+		t.Errorf("constrained labels = %v, violations %v", km.Labels.RawVector().Data, km.Violations)
+	}
+
+	// Three rows which must be in different clusters do not fit in two.
+	km = newModel(&Constraints{CannotLink: [][2]int{{0, 1}, {1, 3}, {0, 3}}})
+	if err := km.FitModel(twoGroups()); err != nil {
+		t.Fatal(err)
+	}
+	if len(km.Violations) != 1 || km.Violations[0].MustLink {
+		t.Errorf("violations = %v, want one cannot-link", km.Violations)
+	}
+
+	km = newModel(c)
+	km.CompressRows = true
+	if err := km.FitModel(twoGroups()); err == nil {
+		t.Error("FitModel() with constraints and compressed rows, want error")
+	}
+	km = newModel(&Constraints{MustLink: [][2]int{{0, 10}}})
+	if err := km.FitModel(twoGroups()); err == nil {
+		t.Error("FitModel() with constraint out of range, want error")
+	}
+}
+
+func TestKPrototypes_Constraints(t *testing.T) {
+	X := NewDenseMatrix(8, 2, []float64{
+		0, 0.1,
+		0, 0.2,
+		0, 0.1,
+		0, 0.3,
+		1, 5.1,
+		1, 5.2,
+		1, 5.0,
+		1, 5.3,
+	})
+	kp := NewKPrototypes(HammingDistance, nil, []int{0}, 2, 1, 20, [][]float64{{1}}, 1, "")
+	kp.MixedInitFunc = InitCaoMixed
+	kp.Seed = 1
+	kp.Constraints = &Constraints{MustLink: [][2]int{{1, 6}, {6, 3}}, CannotLink: [][2]int{{0, 2}}}
+	if err := kp.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+	labels := kp.Labels.RawVector().Data
+	if kp.Violations != nil || labels[1] != labels[6] || labels[3] != labels[6] || labels[0] == labels[2] {
+		t.Errorf("constrained labels = %v, violations %v", labels, kp.Violations)
+	}
+}
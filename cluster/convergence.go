@@ -0,0 +1,58 @@
+package cluster
+
+import "time"
+
+// StopReason describes which rule ended fitting of the model.
+type StopReason int
+
+const (
+	// StopNone means that model was not fitted yet.
+	StopNone StopReason = iota
+	// StopNoChange means that no label changed during the last iteration.
+	StopNoChange
+	// StopMaxIterations means that MaxIterationNumber iterations were done.
+	StopMaxIterations
+	// StopCostTolerance means that relative improvement of the cost was
+	// below CostTolerance.
+	StopCostTolerance
+	// StopMovedTolerance means that fraction of rows which changed cluster was
+	// below MovedTolerance.
+	StopMovedTolerance
+	// StopTimeLimit means that fitting took longer than TimeLimit.
+	StopTimeLimit
+)
+
+var stopReasonNames = []string{"none", "no change", "max iterations", "cost tolerance", "moved tolerance", "time limit"}
+
+func (r StopReason) String() string {
+	if r < 0 || int(r) >= len(stopReasonNames) {
+		return "unknown"
+	}
+	return stopReasonNames[r]
+}
+
+// Converged reports whether fitting ended because clusters became stable, as
+// opposed to running out of iterations or time.
+func (r StopReason) Converged() bool {
+	return r == StopNoChange || r == StopCostTolerance || r == StopMovedTolerance
+}
+
+// stopRule checks stopping rules after an iteration with given cost, where
+// moved rows out of rows changed their cluster, both are sums of sample
+// weights for weighted fits. Zero tolerances and time limit disable
+// corresponding rules.
+func stopRule(prevCost, cost, moved, rows float64, costTol, movedTol float64, start time.Time, limit time.Duration) (StopReason, bool) {
+	if moved == 0 {
+		return StopNoChange, true
+	}
+	if costTol > 0 && prevCost > 0 && (prevCost-cost)/prevCost < costTol {
+		return StopCostTolerance, true
+	}
+	if movedTol > 0 && moved/rows < movedTol {
+		return StopMovedTolerance, true
+	}
+	if limit > 0 && time.Since(start) >= limit {
+		return StopTimeLimit, true
+	}
+	return StopNone, false
+}
@@ -0,0 +1,128 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_stopRule(t *testing.T) {
+	start := time.Now()
+	type args struct {
+		prevCost, cost    float64
+		moved, rows       float64
+		costTol, movedTol float64
+		start             time.Time
+		limit             time.Duration
+	}
+	tests := []struct {
+		args     args
+		want     StopReason
+		wantStop bool
+	}{
+		{args: args{prevCost: 10, cost: 8, moved: 0, rows: 100, start: start}, want: StopNoChange, wantStop: true},
+		{args: args{prevCost: 10, cost: 8, moved: 5, rows: 100, start: start}, want: StopNone, wantStop: false},
+		{args: args{prevCost: 10, cost: 9.95, moved: 5, rows: 100, costTol: 0.01, start: start}, want: StopCostTolerance, wantStop: true},
+		{args: args{prevCost: 10, cost: 8, moved: 5, rows: 100, costTol: 0.01, start: start}, want: StopNone, wantStop: false},
+		{args: args{prevCost: 10, cost: 11, moved: 5, rows: 100, costTol: 0.01, start: start}, want: StopCostTolerance, wantStop: true},
+		{args: args{prevCost: 10, cost: 8, moved: 5, rows: 100, movedTol: 0.1, start: start}, want: StopMovedTolerance, wantStop: true},
+		{args: args{prevCost: 10, cost: 8, moved: 20, rows: 100, movedTol: 0.1, start: start}, want: StopNone, wantStop: false},
+		{args: args{prevCost: 10, cost: 8, moved: 20, rows: 100, start: start.Add(-time.Minute), limit: time.Second}, want: StopTimeLimit, wantStop: true},
+		{args: args{prevCost: 10, cost: 8, moved: 20, rows: 100, start: start, limit: time.Hour}, want: StopNone, wantStop: false},
+	}
+	for i, tt := range tests {
+		a := tt.args
+		got, stop := stopRule(a.prevCost, a.cost, a.moved, a.rows, a.costTol, a.movedTol, a.start, a.limit)
+		if got != tt.want || stop != tt.wantStop {
+			t.Errorf("%d. stopRule() = %v, %v, want %v, %v", i, got, stop, tt.want, tt.wantStop)
+		}
+	}
+}
+
+func TestStopReason_String(t *testing.T) {
+	tests := []struct {
+		r    StopReason
+		want string
+	}{
+		{r: StopNoChange, want: "no change"},
+		{r: StopTimeLimit, want: "time limit"},
+		{r: StopReason(42), want: "unknown"},
+	}
+	for _, tt := range tests {
+		if got := tt.r.String(); got != tt.want {
+			t.Errorf("StopReason.String() = %v, want %v", got, tt.want)
+		}
+	}
+}
+
+func TestKModes_FitModelStopReason(t *testing.T) {
+	initMatrixKModes()
+
+	km := NewKModes(HammingDistance, InitCao, 2, 1, 10, [][]float64{{1, 1}}, "")
+	if err := km.FitModel(m1); err != nil {
+		t.Fatalf("KModes.FitModel() error = %v", err)
+	}
+	if km.StopReason != StopNoChange || !km.IsFitted || km.Iterations != 1 {
+		t.Errorf("KModes.FitModel() stopped by %v after %d iterations, fitted = %v", km.StopReason, km.Iterations, km.IsFitted)
+	}
+
+	kp := NewKPrototypes(HammingDistance, InitCao, []int{1}, 2, 1, 10, [][]float64{{1}}, 1, "")
+	if err := kp.FitModel(m1); err != nil {
+		t.Fatalf("KPrototypes.FitModel() error = %v", err)
+	}
+	if kp.StopReason != StopNoChange || !kp.IsFitted || kp.Iterations != 1 {
+		t.Errorf("KPrototypes.FitModel() stopped by %v after %d iterations, fitted = %v", kp.StopReason, kp.Iterations, kp.IsFitted)
+	}
+}
+
+func TestFitModelTimeLimit(t *testing.T) {
+	X := randomCategorical(100, 5, 3, 8)
+	km := NewKModes(HammingDistance, InitRandom, 4, 1, 50, [][]float64{{1}}, "")
+	km.Seed = 1
+	km.TimeLimit = time.Nanosecond
+	if err := km.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+	if km.StopReason != StopTimeLimit || !km.IsFitted {
+		t.Errorf("KModes.FitModel() stopped by %v, fitted = %v", km.StopReason, km.IsFitted)
+	}
+	if _, err := km.Predict(X); err != nil {
+		t.Errorf("KModes.Predict() after time limit error = %v", err)
+	}
+
+	kp := NewKPrototypes(HammingDistance, InitRandom, []int{0, 1, 2, 3}, 4, 1, 50, [][]float64{{1}}, 1, "")
+	kp.Seed = 1
+	kp.TimeLimit = time.Nanosecond
+	if err := kp.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+	if kp.StopReason != StopTimeLimit || !kp.IsFitted {
+		t.Errorf("KPrototypes.FitModel() stopped by %v, fitted = %v", kp.StopReason, kp.IsFitted)
+	}
+}
+
+func TestFitModelMaxIterations(t *testing.T) {
+	X := randomCategorical(100, 5, 3, 8)
+	km := NewKModes(HammingDistance, InitRandom, 4, 1, 1, [][]float64{{1}}, "")
+	km.Seed = 1
+	if err := km.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+	if km.StopReason != StopMaxIterations || !km.IsFitted {
+		t.Errorf("KModes.FitModel() stopped by %v, fitted = %v", km.StopReason, km.IsFitted)
+	}
+	if _, err := km.Predict(X); err != nil {
+		t.Errorf("KModes.Predict() after max iterations error = %v", err)
+	}
+
+	kp := NewKPrototypes(HammingDistance, InitRandom, []int{0, 1, 2, 3}, 4, 1, 1, [][]float64{{1}}, 1, "")
+	kp.Seed = 1
+	if err := kp.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+	if kp.StopReason != StopMaxIterations || !kp.IsFitted {
+		t.Errorf("KPrototypes.FitModel() stopped by %v, fitted = %v", kp.StopReason, kp.IsFitted)
+	}
+	if _, err := kp.Predict(X); err != nil {
+		t.Errorf("KPrototypes.Predict() after max iterations error = %v", err)
+	}
+}
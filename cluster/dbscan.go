@@ -0,0 +1,112 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+)
+
+// NoiseLabel is the label of rows which do not belong to any cluster found by
+// density based algorithms.
+const NoiseLabel = -1
+
+// DBSCAN is a basic class for the DBSCAN algorithm. Clusters are groups of
+// core rows, which have at least MinSamples rows (including themselves) within
+// distance Eps, together with rows within Eps of a core row. Other rows are
+// noise. The number of clusters is not fixed in advance.
+type DBSCAN struct {
+	DistanceFunc   DistanceFunction
+	Eps            float64 // largest distance of rows in a neighborhood
+	MinSamples     int     // number of rows in a neighborhood of a core row, including the row
+	ClustersNumber int     // number of clusters found by the last fit
+	CoreSamples    []int   // indexes of core rows in ascending order
+	LabelsCounter  []int
+	Labels         *DenseVector // cluster of every row, NoiseLabel for noise
+	IsFitted       bool
+}
+
+// NewDBSCAN implements constructor for the DBSCAN struct.
+func NewDBSCAN(dist DistanceFunction, eps float64, minSamples int) *DBSCAN {
+	return &DBSCAN{
+		DistanceFunc: dist,
+		Eps:          eps,
+		MinSamples:   minSamples,
+	}
+}
+
+// FitModel finds clusters of rows of X using DistanceFunc. Distances are
+// computed on the fly, without storing the distance matrix.
+func (db *DBSCAN) FitModel(X *DenseMatrix) error {
+	if db.DistanceFunc == nil {
+		return errors.New("dbscan: distance function is not set")
+	}
+	xRows, _ := X.Dims()
+	return db.fit(xRows, dataRowDistances(X, db.DistanceFunc))
+}
+
+// FitDistances finds clusters of the dataset described by distance matrix D.
+func (db *DBSCAN) FitDistances(D DistanceMatrix) error {
+	return db.fit(D.Len(), matrixRowDistances(D))
+}
+
+func (db *DBSCAN) fit(n int, distances rowDistances) error {
+	if db.Eps < 0 || db.MinSamples < 1 {
+		return errors.New("dbscan: wrong initialization parameters (eps should be >=0, min samples >=1)")
+	}
+	db.IsFitted = false
+
+	neighbors := make([][]int, n)
+	row := make([]float64, n)
+	db.CoreSamples = nil
+	for i := 0; i < n; i++ {
+		if err := distances(i, row); err != nil {
+			return fmt.Errorf("dbscan: %v", err)
+		}
+		for j, d := range row {
+			if d <= db.Eps {
+				neighbors[i] = append(neighbors[i], j)
+			}
+		}
+		if len(neighbors[i]) >= db.MinSamples {
+			db.CoreSamples = append(db.CoreSamples, i)
+		}
+	}
+	isCore := make([]bool, n)
+	for _, i := range db.CoreSamples {
+		isCore[i] = true
+	}
+
+	db.Labels = NewDenseVector(n, nil)
+	for i := 0; i < n; i++ {
+		db.Labels.SetVec(i, NoiseLabel)
+	}
+	db.LabelsCounter = nil
+	var queue []int
+	for _, i := range db.CoreSamples {
+		if db.Labels.At(i, 0) != NoiseLabel {
+			continue
+		}
+		// Expand the new cluster from core rows, border rows join the first
+		// cluster which reaches them.
+		label := len(db.LabelsCounter)
+		db.LabelsCounter = append(db.LabelsCounter, 1)
+		db.Labels.SetVec(i, float64(label))
+		queue = append(queue[:0], i)
+		for len(queue) > 0 {
+			p := queue[0]
+			queue = queue[1:]
+			for _, q := range neighbors[p] {
+				if db.Labels.At(q, 0) != NoiseLabel {
+					continue
+				}
+				db.Labels.SetVec(q, float64(label))
+				db.LabelsCounter[label]++
+				if isCore[q] {
+					queue = append(queue, q)
+				}
+			}
+		}
+	}
+	db.ClustersNumber = len(db.LabelsCounter)
+	db.IsFitted = true
+	return nil
+}
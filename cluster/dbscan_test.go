@@ -0,0 +1,71 @@
+package cluster
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDBSCAN_FitDistances(t *testing.T) {
+	D := lineDistances(0, 1, 2, 10, 11, 12, 30)
+	tests := []struct {
+		eps         float64
+		minSamples  int
+		wantLabels  []float64
+		wantCore    []int
+		wantCounter []int
+	}{
+		{1.5, 2, []float64{0, 0, 0, 1, 1, 1, -1}, []int{0, 1, 2, 3, 4, 5}, []int{3, 3}},
+		{1.5, 3, []float64{0, 0, 0, 1, 1, 1, -1}, []int{1, 4}, []int{3, 3}},
+		{1.5, 4, []float64{-1, -1, -1, -1, -1, -1, -1}, nil, nil},
+		{10, 2, []float64{0, 0, 0, 0, 0, 0, -1}, []int{0, 1, 2, 3, 4, 5}, []int{6}},
+		{20, 1, []float64{0, 0, 0, 0, 0, 0, 0}, []int{0, 1, 2, 3, 4, 5, 6}, []int{7}},
+	}
+	for _, tt := range tests {
+		db := NewDBSCAN(nil, tt.eps, tt.minSamples)
+		if err := db.FitDistances(D); err != nil {
+			t.Fatal(err)
+		}
+		if got := db.Labels.RawVector().Data; !reflect.DeepEqual(got, tt.wantLabels) {
+			t.Errorf("DBSCAN(%v, %d) labels = %v, want %v", tt.eps, tt.minSamples, got, tt.wantLabels)
+		}
+		if !reflect.DeepEqual(db.CoreSamples, tt.wantCore) {
+			t.Errorf("DBSCAN(%v, %d) core samples = %v, want %v", tt.eps, tt.minSamples, db.CoreSamples, tt.wantCore)
+		}
+		if !reflect.DeepEqual(db.LabelsCounter, tt.wantCounter) || db.ClustersNumber != len(tt.wantCounter) {
+			t.Errorf("DBSCAN(%v, %d) labels counter = %v, want %v", tt.eps, tt.minSamples, db.LabelsCounter, tt.wantCounter)
+		}
+	}
+
+	if err := NewDBSCAN(nil, -1, 2).FitDistances(D); err == nil {
+		t.Error("FitDistances() with negative eps, want error")
+	}
+	if err := NewDBSCAN(nil, 1, 0).FitDistances(D); err == nil {
+		t.Error("FitDistances() with zero min samples, want error")
+	}
+}
+
+func TestDBSCAN_FitModel(t *testing.T) {
+	X := NewDenseMatrix(7, 3, []float64{
+		0, 0, 0,
+		0, 0, 1,
+		0, 1, 0,
+		5, 5, 5,
+		5, 5, 6,
+		6, 5, 5,
+		9, 8, 7,
+	})
+	db := NewDBSCAN(HammingDistance, 1, 2)
+	if err := db.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{0, 0, 0, 1, 1, 1, -1}; !reflect.DeepEqual(db.Labels.RawVector().Data, want) {
+		t.Errorf("labels = %v, want %v", db.Labels.RawVector().Data, want)
+	}
+
+	if err := NewDBSCAN(HammingDistance, 1, 2).FitModel(NewDenseMatrix(1, 1, nil)); err != nil {
+		t.Errorf("FitModel() of single row error = %v", err)
+	}
+	if err := NewDBSCAN(nil, 1, 2).FitModel(X); err == nil {
+		t.Error("FitModel() without distance, want error")
+	}
+}
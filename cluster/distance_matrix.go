@@ -0,0 +1,182 @@
+package cluster
+
+import (
+	"fmt"
+	"sync"
+)
+
+// DistanceMatrix holds precomputed pairwise distances between rows of a
+// dataset. Algorithms which accept it never call the distance function, which
+// pays off for expensive distances.
+type DistanceMatrix interface {
+	// Len returns the number of rows.
+	Len() int
+	// Distance returns distance between rows i and j.
+	Distance(i, j int) float64
+}
+
+// FullDistanceMatrix stores all n*n distances in row-major order.
+type FullDistanceMatrix struct {
+	n    int
+	data []float64
+}
+
+// NewFullDistanceMatrix creates FullDistanceMatrix for n rows. If data is nil
+// new slice is allocated, otherwise it must have n*n elements.
+func NewFullDistanceMatrix(n int, data []float64) (*FullDistanceMatrix, error) {
+	if data == nil {
+		data = make([]float64, n*n)
+	}
+	if len(data) != n*n {
+		return nil, fmt.Errorf("full distance matrix: wrong data length %d, want %d", len(data), n*n)
+	}
+	return &FullDistanceMatrix{n: n, data: data}, nil
+}
+
+// Len returns the number of rows.
+func (m *FullDistanceMatrix) Len() int {
+	return m.n
+}
+
+// Distance returns distance between rows i and j.
+func (m *FullDistanceMatrix) Distance(i, j int) float64 {
+	return m.data[i*m.n+j]
+}
+
+// Set sets distance between rows i and j (in both directions).
+func (m *FullDistanceMatrix) Set(i, j int, d float64) {
+	m.data[i*m.n+j] = d
+	m.data[j*m.n+i] = d
+}
+
+// CondensedDistanceMatrix stores only distances above the diagonal, in the
+// same order as scipy's pdist: (0,1), (0,2), ..., (0,n-1), (1,2), ... It uses
+// n*(n-1)/2 elements, distance of a row to itself is 0.
+type CondensedDistanceMatrix struct {
+	n    int
+	data []float64
+}
+
+// NewCondensedDistanceMatrix creates CondensedDistanceMatrix for n rows. If
+// data is nil new slice is allocated, otherwise it must have n*(n-1)/2
+// elements.
+func NewCondensedDistanceMatrix(n int, data []float64) (*CondensedDistanceMatrix, error) {
+	size := n * (n - 1) / 2
+	if data == nil {
+		data = make([]float64, size)
+	}
+	if len(data) != size {
+		return nil, fmt.Errorf("condensed distance matrix: wrong data length %d, want %d", len(data), size)
+	}
+	return &CondensedDistanceMatrix{n: n, data: data}, nil
+}
+
+// Len returns the number of rows.
+func (m *CondensedDistanceMatrix) Len() int {
+	return m.n
+}
+
+// Distance returns distance between rows i and j.
+func (m *CondensedDistanceMatrix) Distance(i, j int) float64 {
+	if i == j {
+		return 0
+	}
+	return m.data[m.index(i, j)]
+}
+
+// Set sets distance between rows i and j, i must differ from j.
+func (m *CondensedDistanceMatrix) Set(i, j int, d float64) {
+	m.data[m.index(i, j)] = d
+}
+
+// RawData returns the condensed distances.
+func (m *CondensedDistanceMatrix) RawData() []float64 {
+	return m.data
+}
+
+func (m *CondensedDistanceMatrix) index(i, j int) int {
+	if i > j {
+		i, j = j, i
+	}
+	return m.n*i - i*(i+1)/2 + j - i - 1
+}
+
+// ComputeDistanceMatrix computes pairwise distances between rows of X, which
+// may be a DenseMatrix or a compact CategoricalMatrix, with dist, using given
+// number of goroutines (at least one is used). The distance function must be
+// safe for concurrent use.
+func ComputeDistanceMatrix(X Matrix, dist DistanceFunction, workers int) (*CondensedDistanceMatrix, error) {
+	xRows, xCols := X.Dims()
+	D, err := NewCondensedDistanceMatrix(xRows, nil)
+	if err != nil {
+		return nil, err
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	rawDist := RawDistance(dist)
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	rows := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a, b := make([]float64, xCols), make([]float64, xCols)
+			for i := range rows {
+				row := X.RowTo(a, i)
+				for j := i + 1; j < xRows; j++ {
+					d, err := rawDist(row, X.RowTo(b, j))
+					if err != nil {
+						once.Do(func() {
+							firstErr = fmt.Errorf("distance matrix: cannot compute distance between rows %d and %d: %v", i, j, err)
+						})
+						break
+					}
+					D.Set(i, j, d)
+				}
+			}
+		}()
+	}
+	for i := 0; i < xRows; i++ {
+		rows <- i
+	}
+	close(rows)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return D, nil
+}
+
+// rowDistances fills out with distances of the row i to all rows.
+type rowDistances func(i int, out []float64) error
+
+// matrixRowDistances reads distances of rows from D.
+func matrixRowDistances(D DistanceMatrix) rowDistances {
+	return func(i int, out []float64) error {
+		for j := range out {
+			out[j] = D.Distance(i, j)
+		}
+		return nil
+	}
+}
+
+// dataRowDistances computes distances of rows of X with dist, so that
+// algorithms need only O(n) memory for distances.
+func dataRowDistances(X *DenseMatrix, dist DistanceFunction) rowDistances {
+	rawDist := RawDistance(dist)
+	return func(i int, out []float64) error {
+		for j := range out {
+			d, err := rawDist(X.RawRowView(i), X.RawRowView(j))
+			if err != nil {
+				return fmt.Errorf("cannot compute distance between rows %d and %d: %v", i, j, err)
+			}
+			out[j] = d
+		}
+		return nil
+	}
+}
@@ -0,0 +1,82 @@
+package cluster
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCondensedDistanceMatrix(t *testing.T) {
+	D, err := NewCondensedDistanceMatrix(4, []float64{1, 2, 3, 4, 5, 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		i, j int
+		want float64
+	}{
+		{i: 0, j: 0, want: 0},
+		{i: 0, j: 1, want: 1},
+		{i: 0, j: 3, want: 3},
+		{i: 1, j: 2, want: 4},
+		{i: 3, j: 1, want: 5},
+		{i: 2, j: 3, want: 6},
+	}
+	for _, tt := range tests {
+		if got := D.Distance(tt.i, tt.j); got != tt.want {
+			t.Errorf("CondensedDistanceMatrix.Distance(%d, %d) = %v, want %v", tt.i, tt.j, got, tt.want)
+		}
+	}
+
+	if _, err := NewCondensedDistanceMatrix(4, []float64{1, 2, 3}); err == nil {
+		t.Errorf("NewCondensedDistanceMatrix() with wrong data length did not fail")
+	}
+	if _, err := NewFullDistanceMatrix(2, []float64{1, 2, 3}); err == nil {
+		t.Errorf("NewFullDistanceMatrix() with wrong data length did not fail")
+	}
+}
+
+func TestComputeDistanceMatrix(t *testing.T) {
+	X := randomCategorical(40, 5, 3, 1)
+	for _, workers := range []int{0, 1, 4} {
+		D, err := ComputeDistanceMatrix(X, HammingDistance, workers)
+		if err != nil {
+			t.Fatalf("ComputeDistanceMatrix() error = %v", err)
+		}
+		full, _ := NewFullDistanceMatrix(40, nil)
+		for i := 0; i < 40; i++ {
+			for j := i + 1; j < 40; j++ {
+				want, _ := RawHammingDistance(X.RawRowView(i), X.RawRowView(j))
+				full.Set(i, j, want)
+				if got := D.Distance(i, j); got != want {
+					t.Fatalf("ComputeDistanceMatrix() distance(%d, %d) = %v, want %v", i, j, got, want)
+				}
+			}
+		}
+		for i := 0; i < 40; i++ {
+			for j := 0; j < 40; j++ {
+				if D.Distance(i, j) != full.Distance(i, j) {
+					t.Fatalf("FullDistanceMatrix.Distance(%d, %d) = %v, want %v", i, j, full.Distance(i, j), D.Distance(i, j))
+				}
+			}
+		}
+	}
+
+	// Compact matrices give the same distances.
+	compact, err := NewCategoricalMatrixFromDense(X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := ComputeDistanceMatrix(X, HammingDistance, 1)
+	got, err := ComputeDistanceMatrix(compact, HammingDistance, 2)
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("ComputeDistanceMatrix() of compact matrix = %v, %v, want %v", got, err, want)
+	}
+
+	failing := func(a, b *DenseVector) (float64, error) {
+		return 0, errors.New("failure")
+	}
+	if _, err := ComputeDistanceMatrix(X, failing, 2); err == nil {
+		t.Errorf("ComputeDistanceMatrix() with failing distance did not fail")
+	}
+}
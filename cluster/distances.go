@@ -4,6 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"reflect"
+
+	"gonum.org/v1/gonum/mat"
 )
 
 var (
@@ -56,23 +59,160 @@ func EuclideanDistance(a, b *DenseVector) (float64, error) {
 	return math.Sqrt(distance), nil
 }
 
-// SetWeights sets the weight vector used in WeightedHammingDistance function.
+// MixedDistance returns distance for mixed data, as used by k-prototypes:
+// gamma times catDist of categorical attributes (columns listed in
+// categorical) plus Euclidean distance of the other attributes. Numerical
+// attributes should be scaled to comparable ranges beforehand.
+func MixedDistance(categorical []int, gamma float64, catDist DistanceFunction) DistanceFunction {
+	rawCat := RawDistance(catDist)
+	return func(a, b *DenseVector) (float64, error) {
+		if a.Len() != b.Len() {
+			return -1, errors.New("mixed distance: vectors lengths do not match")
+		}
+		isCat := make([]bool, a.Len())
+		for _, j := range categorical {
+			if j < 0 || j >= a.Len() {
+				return -1, fmt.Errorf("mixed distance: categorical column %d out of range", j)
+			}
+			isCat[j] = true
+		}
+		catA := make([]float64, 0, len(categorical))
+		catB := make([]float64, 0, len(categorical))
+		var squares float64
+		for j, cat := range isCat {
+			if cat {
+				catA, catB = append(catA, a.At(j, 0)), append(catB, b.At(j, 0))
+				continue
+			}
+			diff := a.At(j, 0) - b.At(j, 0)
+			squares += diff * diff
+		}
+		d, err := rawCat(catA, catB)
+		if err != nil {
+			return -1, fmt.Errorf("mixed distance: %v", err)
+		}
+		return gamma*d + math.Sqrt(squares), nil
+	}
+}
+
+// RawDistanceFunction compute distance between two vectors stored in raw
+// slices. It is used internally by the models, as it does not need to wrap rows
+// and centroids in vectors.
+type RawDistanceFunction func(a, b []float64) (float64, error)
+
+// RawHammingDistance is HammingDistance computed on raw slices.
+func RawHammingDistance(a, b []float64) (float64, error) {
+	if len(a) != len(b) {
+		return -1, errors.New("hamming distance: vectors lengths do not match")
+	}
+	var distance float64
+	for i, v := range a {
+		if v != b[i] {
+			distance++
+		}
+	}
+	return distance, nil
+}
+
+// RawWeightedHammingDistance is WeightedHammingDistance computed on raw
+// slices.
+func RawWeightedHammingDistance(a, b []float64) (float64, error) {
+	return weightedHamming(a, b, weightVector.RawVector().Data)
+}
+
+// weightedHamming is RawWeightedHammingDistance with given attribute weights.
+func weightedHamming(a, b, weights []float64) (float64, error) {
+	if len(a) != len(b) {
+		return -1, errors.New("hamming distance: vectors lengths do not match")
+	}
+	if len(a) != len(weights) {
+		return -1, fmt.Errorf("weighted hamming distance: wrong weight vector length: %d", len(weights))
+	}
+
+	var distance float64
+	for i, v := range a {
+		if v != b[i] {
+			distance += weights[i]
+		}
+	}
+	return distance, nil
+}
+
+// RawEuclideanDistance is EuclideanDistance computed on raw slices.
+func RawEuclideanDistance(a, b []float64) (float64, error) {
+	if len(a) != len(b) {
+		return -1, errors.New("euclidean distance: vectors lengths do not match")
+	}
+	var distance float64
+	for i, v := range a {
+		diff := v - b[i]
+		distance += diff * diff
+	}
+	return math.Sqrt(distance), nil
+}
+
+// RawDistance returns the raw slices version of distance function. Distances
+// from this package are replaced by their raw implementations, any other
+// function is wrapped in an adapter.
+func RawDistance(dist DistanceFunction) RawDistanceFunction {
+	switch reflect.ValueOf(dist).Pointer() {
+	case reflect.ValueOf(HammingDistance).Pointer():
+		return RawHammingDistance
+	case reflect.ValueOf(WeightedHammingDistance).Pointer():
+		return RawWeightedHammingDistance
+	case reflect.ValueOf(EuclideanDistance).Pointer():
+		return RawEuclideanDistance
+	}
+	return func(a, b []float64) (float64, error) {
+		return dist(NewDenseVector(len(a), a), NewDenseVector(len(b), b))
+	}
+}
+
+// modelDistance returns the raw distance used by a model with weightVectors.
+// WeightedHammingDistance is bound to the first weight vector of the model
+// instead of the weights set by SetWeights, so that models with different
+// weights may be loaded and used concurrently.
+func modelDistance(dist DistanceFunction, weightVectors [][]float64) RawDistanceFunction {
+	if isWeighted(dist) && len(weightVectors) > 0 {
+		weights := weightVectors[0]
+		return func(a, b []float64) (float64, error) {
+			return weightedHamming(a, b, weights)
+		}
+	}
+	return RawDistance(dist)
+}
+
+// boundDistance returns dist for initialization functions of a model with
+// weightVectors. WeightedHammingDistance is bound to the first weight vector of
+// the model like in modelDistance.
+func boundDistance(dist DistanceFunction, weightVectors [][]float64) DistanceFunction {
+	if isWeighted(dist) && len(weightVectors) > 0 {
+		weights := weightVectors[0]
+		return func(a, b *DenseVector) (float64, error) {
+			return weightedHamming(mat.Col(nil, 0, a), mat.Col(nil, 0, b), weights)
+		}
+	}
+	return dist
+}
+
+// SetWeights sets the weight vector used in WeightedHammingDistance function
+// called directly. Models bind their own WeightVectors instead, they neither
+// read nor set this vector.
 func SetWeights(newWeights []float64) {
 	weightVector = NewDenseVector(len(newWeights), newWeights)
 }
 
 // ComputeWeights derives weights based on the frequency of attribute values
 // (more different values means lower weight).
-func ComputeWeights(X *DenseMatrix, imp float64) []float64 {
+func ComputeWeights(X Matrix, imp float64) []float64 {
 	xRows, xCols := X.Dims()
 
 	weights := make([]float64, xCols)
 
 	for i := 0; i < xCols; i++ {
-		column := X.ColView(i)
 		frequencies := make(map[float64]float64)
 		for j := 0; j < xRows; j++ {
-			frequencies[column.At(j, 0)] = frequencies[column.At(j, 0)] + 1
+			frequencies[X.At(j, i)] = frequencies[X.At(j, i)] + 1
 		}
 
 		if w := 1 / float64(len(frequencies)); w == 1 {
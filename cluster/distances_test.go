@@ -3,6 +3,8 @@ package cluster
 import (
 	"reflect"
 	"testing"
+
+	"gonum.org/v1/gonum/mat"
 )
 
 var mw1, mw2, mw3 *DenseMatrix
@@ -154,3 +156,75 @@ func TestComputeWeights(t *testing.T) {
 
 	}
 }
+
+func TestRawDistance(t *testing.T) {
+	initVectorsdist()
+	SetWeights(w1)
+	custom := func(a, b *DenseVector) (float64, error) {
+		return 2 * float64(a.Len()+b.Len()), nil
+	}
+
+	tests := []struct {
+		name string
+		dist DistanceFunction
+	}{
+		{name: "HammingDistance", dist: HammingDistance},
+		{name: "WeightedHammingDistance", dist: WeightedHammingDistance},
+		{name: "EuclideanDistance", dist: EuclideanDistance},
+		{name: "custom", dist: custom},
+	}
+	for _, tt := range tests {
+		want, wantErr := tt.dist(c, d)
+		got, err := RawDistance(tt.dist)(c.RawVector().Data, d.RawVector().Data)
+		if got != want || (err != nil) != (wantErr != nil) {
+			t.Errorf("RawDistance(%s)() = %v, %v, want %v, %v", tt.name, got, err, want, wantErr)
+		}
+
+		_, wantErr = tt.dist(a, c)
+		_, err = RawDistance(tt.dist)(a.RawVector().Data, c.RawVector().Data)
+		if (err != nil) != (wantErr != nil) {
+			t.Errorf("RawDistance(%s)() error = %v, want %v", tt.name, err, wantErr)
+		}
+	}
+}
+
+func TestMixedDistance(t *testing.T) {
+	initVectorsdist()
+	tests := []struct {
+		categorical []int
+		gamma       float64
+		v1, v2      *DenseVector
+		want        float64
+		wantErr     bool
+	}{
+		{categorical: []int{1, 2}, gamma: 0.5, v1: c, v2: d, want: 1.5},
+		{categorical: []int{0, 2}, gamma: 0.5, v1: c, v2: d, want: 1.4142135623730951},
+		{categorical: []int{0, 1, 2, 3}, gamma: 2, v1: c, v2: d, want: 4},
+		{categorical: []int{0}, gamma: 1, v1: a, v2: c, wantErr: true},
+		{categorical: []int{5}, gamma: 1, v1: c, v2: d, wantErr: true},
+	}
+	for i, tt := range tests {
+		got, err := MixedDistance(tt.categorical, tt.gamma, HammingDistance)(tt.v1, tt.v2)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%d. MixedDistance() error = %v, wantErr %v", i, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("%d. MixedDistance() = %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+func BenchmarkHammingDistance(b *testing.B) {
+	x := NewDenseMatrix(2, 50, nil)
+	for n := 0; n < b.N; n++ {
+		HammingDistance(&DenseVector{x.RowView(0).(*mat.VecDense)}, &DenseVector{x.RowView(1).(*mat.VecDense)})
+	}
+}
+
+func BenchmarkRawHammingDistance(b *testing.B) {
+	x := NewDenseMatrix(2, 50, nil)
+	for n := 0; n < b.N; n++ {
+		RawHammingDistance(x.RawRowView(0), x.RawRowView(1))
+	}
+}
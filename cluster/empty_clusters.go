@@ -0,0 +1,90 @@
+package cluster
+
+import "math/rand"
+
+// EmptyClusterStrategy defines what happens with a cluster which has no
+// members left during fitting.
+type EmptyClusterStrategy int
+
+const (
+	// EmptyClusterRandom moves a randomly chosen row to the empty cluster.
+	EmptyClusterRandom EmptyClusterStrategy = iota
+	// EmptyClusterFarthest moves the row which is the farthest from its
+	// centroid to the empty cluster.
+	EmptyClusterFarthest
+	// EmptyClusterSplitLargest splits the largest cluster - its row farthest
+	// from the centroid is moved to the empty cluster.
+	EmptyClusterSplitLargest
+	// EmptyClusterDrop drops the empty cluster, it is not used for assignment
+	// anymore and its index is reported in DroppedClusters.
+	EmptyClusterDrop
+)
+
+// emptyClusterRow chooses the row which becomes the only member of an empty
+// cluster. costs holds distances of rows to their centroids. Only rows from
+// clusters with more than one member are considered, so that no new empty
+// cluster is created. It returns false if there is no such row.
+func emptyClusterRow(strategy EmptyClusterStrategy, rnd *rand.Rand, labels *DenseVector, counter []int, costs []float64) (int, bool) {
+	index := -1
+	switch strategy {
+	case EmptyClusterRandom:
+		candidates := make([]int, 0)
+		for i := range costs {
+			if counter[int(labels.At(i, 0))] > 1 {
+				candidates = append(candidates, i)
+			}
+		}
+		if len(candidates) > 0 {
+			index = candidates[rnd.Intn(len(candidates))]
+		}
+	case EmptyClusterFarthest:
+		index = farthestRow(-1, labels, counter, costs)
+	case EmptyClusterSplitLargest:
+		largest := 0
+		for i, c := range counter {
+			if c > counter[largest] {
+				largest = i
+			}
+		}
+		index = farthestRow(largest, labels, counter, costs)
+	}
+	return index, index != -1
+}
+
+// farthestRow finds the row with the highest cost among rows of cluster (or
+// all clusters if cluster is -1) having more than one member.
+func farthestRow(cluster int, labels *DenseVector, counter []int, costs []float64) int {
+	index := -1
+	for i, cost := range costs {
+		label := int(labels.At(i, 0))
+		if counter[label] < 2 || (cluster != -1 && label != cluster) {
+			continue
+		}
+		if index == -1 || cost > costs[index] {
+			index = i
+		}
+	}
+	return index
+}
+
+// isDropped checks whether cluster was dropped during fitting.
+func isDropped(dropped []int, cluster int) bool {
+	for _, d := range dropped {
+		if d == cluster {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDropped checks whether cluster was dropped during the last fit, dropped
+// clusters keep their index but get no rows.
+func (km *KModes) IsDropped(cluster int) bool {
+	return isDropped(km.DroppedClusters, cluster)
+}
+
+// IsDropped checks whether cluster was dropped during the last fit, dropped
+// clusters keep their index but get no rows.
+func (km *KPrototypes) IsDropped(cluster int) bool {
+	return isDropped(km.DroppedClusters, cluster)
+}
@@ -0,0 +1,132 @@
+package cluster
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// initSame initializes all centroids with the first row of X, which leaves all
+// clusters but the first one empty after the initial assignment.
+func initSame(X Matrix, clustersNumber int, distFunc DistanceFunction, rnd *rand.Rand) (*DenseMatrix, error) {
+	_, xCols := X.Dims()
+	centroids := NewDenseMatrix(clustersNumber, xCols, nil)
+	for i := 0; i < clustersNumber; i++ {
+		centroids.SetRow(i, X.RowTo(make([]float64, xCols), 0))
+	}
+	return centroids, nil
+}
+
+func Test_emptyClusterRow(t *testing.T) {
+	labels := NewDenseVector(6, []float64{0, 0, 0, 1, 1, 3})
+	counter := []int{3, 2, 0, 1}
+	costs := []float64{1, 4, 2, 3, 5, 9}
+
+	tests := []struct {
+		strategy EmptyClusterStrategy
+		want     int
+		wantOk   bool
+	}{
+		{strategy: EmptyClusterFarthest, want: 4, wantOk: true},
+		{strategy: EmptyClusterSplitLargest, want: 1, wantOk: true},
+		{strategy: EmptyClusterDrop, want: -1, wantOk: false},
+	}
+	for _, tt := range tests {
+		got, ok := emptyClusterRow(tt.strategy, rand.New(rand.NewSource(1)), labels, counter, costs)
+		if got != tt.want || ok != tt.wantOk {
+			t.Errorf("emptyClusterRow(%d) = %v, %v, want %v, %v", tt.strategy, got, ok, tt.want, tt.wantOk)
+		}
+	}
+
+	// Random row must never come from a cluster with a single member.
+	for seed := int64(0); seed < 20; seed++ {
+		got, ok := emptyClusterRow(EmptyClusterRandom, rand.New(rand.NewSource(seed)), labels, counter, costs)
+		if !ok || got == 5 {
+			t.Errorf("emptyClusterRow(EmptyClusterRandom) = %v, %v", got, ok)
+		}
+	}
+
+	// No row can be moved without emptying another cluster.
+	if _, ok := emptyClusterRow(EmptyClusterFarthest, nil, NewDenseVector(2, []float64{0, 1}), []int{1, 1, 0}, []float64{1, 1}); ok {
+		t.Errorf("emptyClusterRow() found a row in singleton clusters")
+	}
+}
+
+func TestKModes_FitModelEmptyClusters(t *testing.T) {
+	X := NewDenseMatrix(8, 2, []float64{
+		1, 1, 1, 1, 1, 2, 2, 2,
+		3, 3, 3, 4, 4, 4, 5, 5,
+	})
+
+	for _, strategy := range []EmptyClusterStrategy{EmptyClusterRandom, EmptyClusterFarthest, EmptyClusterSplitLargest, EmptyClusterDrop} {
+		km := NewKModes(HammingDistance, initSame, 3, 1, 10, [][]float64{{1, 1}}, "")
+		km.EmptyClusterAction = strategy
+		km.Seed = 1
+		if err := km.FitModel(X); err != nil {
+			t.Fatalf("KModes.FitModel() error = %v", err)
+		}
+
+		var total int
+		for i, c := range km.LabelsCounter {
+			total += c
+			if c == 0 && strategy != EmptyClusterDrop {
+				t.Errorf("strategy %d: cluster %d is empty", strategy, i)
+			}
+		}
+		if total != 8 {
+			t.Errorf("strategy %d: KModes.LabelsCounter = %v", strategy, km.LabelsCounter)
+		}
+		for i := 0; i < 8; i++ {
+			if km.IsDropped(int(km.Labels.At(i, 0))) {
+				t.Errorf("strategy %d: row %d assigned to dropped cluster", strategy, i)
+			}
+		}
+		if strategy == EmptyClusterDrop && !reflect.DeepEqual(km.DroppedClusters, []int{1, 2}) {
+			t.Errorf("KModes.DroppedClusters = %v, want %v", km.DroppedClusters, []int{1, 2})
+		}
+		if strategy != EmptyClusterDrop && km.DroppedClusters != nil {
+			t.Errorf("strategy %d: KModes.DroppedClusters = %v", strategy, km.DroppedClusters)
+		}
+	}
+}
+
+func TestKPrototypes_FitModelEmptyClusters(t *testing.T) {
+	X := NewDenseMatrix(8, 2, []float64{
+		1, 1, 2, 1, 3, 2, 4, 2,
+		5, 3, 6, 3, 7, 3, 8, 3,
+	})
+
+	for _, strategy := range []EmptyClusterStrategy{EmptyClusterRandom, EmptyClusterFarthest, EmptyClusterSplitLargest, EmptyClusterDrop} {
+		kp := NewKPrototypes(HammingDistance, initSame, []int{1}, 3, 1, 10, [][]float64{{1}}, 1, "")
+		kp.EmptyClusterAction = strategy
+		kp.Seed = 1
+		// Same records for numerical part as well.
+		kp.MixedInitFunc = func(xCat, xNum *DenseMatrix, clustersNumber int, gamma float64, distFunc DistanceFunction, rnd *rand.Rand) (*DenseMatrix, *DenseMatrix, error) {
+			cat, _ := initSame(xCat, clustersNumber, distFunc, rnd)
+			num, _ := initSame(xNum, clustersNumber, distFunc, rnd)
+			return cat, num, nil
+		}
+		if err := kp.FitModel(X); err != nil {
+			t.Fatalf("KPrototypes.FitModel() error = %v", err)
+		}
+
+		for _, v := range kp.ClusterCentroidsNum.RawMatrix().Data {
+			if math.IsNaN(v) {
+				t.Errorf("strategy %d: KPrototypes.ClusterCentroidsNum = %v", strategy, kp.ClusterCentroidsNum.RawMatrix().Data)
+				break
+			}
+		}
+		for i, c := range kp.LabelsCounter {
+			if c == 0 && strategy != EmptyClusterDrop {
+				t.Errorf("strategy %d: cluster %d is empty", strategy, i)
+			}
+		}
+		if strategy == EmptyClusterDrop && !reflect.DeepEqual(kp.DroppedClusters, []int{1, 2}) {
+			t.Errorf("KPrototypes.DroppedClusters = %v, want %v", kp.DroppedClusters, []int{1, 2})
+		}
+		if kp.IsDropped(1) != (strategy == EmptyClusterDrop) || kp.IsDropped(0) {
+			t.Errorf("strategy %d: KPrototypes.IsDropped() does not match DroppedClusters %v", strategy, kp.DroppedClusters)
+		}
+	}
+}
@@ -5,9 +5,6 @@ import (
 	"math"
 	"math/rand"
 	"sort"
-	"time"
-
-	"gonum.org/v1/gonum/mat"
 )
 
 // KV is a structure that holds key-value pairs of type float64.
@@ -18,57 +15,112 @@ type KV struct {
 
 // InitHuang implements initialization of cluster centroids based on the
 // frequency of attributes as defined in paper written by Z.Huang in 1998.
-func InitHuang(X *DenseMatrix, clustersNumber int, distFunc DistanceFunction) (*DenseMatrix, error) {
-	_, xCols := X.Dims()
+// Attribute values of k synthetic centroids are drawn at random with
+// probability proportional to their frequency, then each synthetic centroid is
+// replaced by the most similar record not chosen yet, so initial centroids are
+// distinct real records (as long as dataset has enough distinct records).
+func InitHuang(X Matrix, clustersNumber int, distFunc DistanceFunction, rnd *rand.Rand) (*DenseMatrix, error) {
+	return initHuang(X, clustersNumber, RawDistance(distFunc), rnd)
+}
+
+// initHuang implements InitHuang with a raw distance, which models bind to
+// their own attribute weights.
+func initHuang(X Matrix, clustersNumber int, rawDist RawDistanceFunction, rnd *rand.Rand) (*DenseMatrix, error) {
+	xRows, xCols := X.Dims()
 	centroids := NewDenseMatrix(clustersNumber, xCols, nil)
 
+	// Draw attribute values of synthetic centroids.
 	freqTable := CreateFrequencyTable(X)
+	for i := 0; i < xCols; i++ {
+		for j := 0; j < clustersNumber; j++ {
+			target := rnd.Float64() * float64(xRows)
+			value := freqTable[i][len(freqTable[i])-1].Key
+			for _, kv := range freqTable[i] {
+				target -= kv.Value
+				if target < 0 {
+					value = kv.Key
+					break
+				}
+			}
+			centroids.Set(j, i, value)
+		}
+	}
+
+	// Replace synthetic centroids with the most similar records.
+	chosen := make([]int, 0, clustersNumber)
+	row, other := make([]float64, xCols), make([]float64, xCols)
 	for j := 0; j < clustersNumber; j++ {
-		for i := 0; i < xCols; i++ {
-			if len(freqTable[i]) > j {
-				centroids.Set(j, i, freqTable[i][j].Key)
-			} else {
-				// Change to setting to randomly chosen value instead of first
-				// one.
-				centroids.Set(j, i, freqTable[i][0].Key)
+		centroid := centroids.RawRowView(j)
+		index, indexDuplicate := -1, -1
+		minDist, minDistDuplicate := math.MaxFloat64, math.MaxFloat64
+		for k := 0; k < xRows; k++ {
+			dist, err := rawDist(X.RowTo(row, k), centroid)
+			if err != nil {
+				return NewDenseMatrix(0, 0, nil), fmt.Errorf("huang init: cannot compute cluster: %v ", err)
+			}
+			if isChosenRecord(X, k, chosen, row, other) {
+				// Record equal to already chosen centroid is used only when
+				// there are no more distinct records.
+				if dist < minDistDuplicate {
+					minDistDuplicate = dist
+					indexDuplicate = k
+				}
+				continue
 			}
+			if dist < minDist {
+				minDist = dist
+				index = k
+			}
+		}
+		if index == -1 {
+			index = indexDuplicate
 		}
+		chosen = append(chosen, index)
+		centroids.SetRow(j, X.RowTo(row, index))
 	}
 
 	return centroids, nil
 }
 
+// isChosenRecord checks whether record k of X is equal to any of chosen
+// records, row and other are buffers for rows of X.
+func isChosenRecord(X Matrix, k int, chosen []int, row, other []float64) bool {
+	row = X.RowTo(row, k)
+	for _, c := range chosen {
+		if c == k {
+			return true
+		}
+		equal := true
+		for i, v := range X.RowTo(other, c) {
+			if row[i] != v {
+				equal = false
+				break
+			}
+		}
+		if equal {
+			return true
+		}
+	}
+	return false
+}
+
 // InitCao implements initialization of cluster centroids based on the frequency
 // and density of attributes as defined in
 //    "A new initialization method for categorical data clustering" by F.Cao(2009)
-func InitCao(X *DenseMatrix, clustersNumber int, distFunc DistanceFunction) (*DenseMatrix, error) {
+func InitCao(X Matrix, clustersNumber int, distFunc DistanceFunction, rnd *rand.Rand) (*DenseMatrix, error) {
+	return initCao(X, clustersNumber, RawDistance(distFunc), nil)
+}
+
+// initCao implements InitCao with a raw distance, frequencies of attribute
+// values are sums of weights of rows if weights is not nil.
+func initCao(X Matrix, clustersNumber int, rawDist RawDistanceFunction, weights []float64) (*DenseMatrix, error) {
 	xRows, xCols := X.Dims()
 	centroids := NewDenseMatrix(clustersNumber, xCols, nil)
-
-	// Compute density table and, int the same time find index of vector with
-	// the highest density.
-	highestDensityIndex := 0
-	maxDensity := 0.0
-	densityTable := make([]float64, xRows)
-	for i := 0; i < xCols; i++ {
-		freq := make(map[float64]int)
-		for j := 0; j < xRows; j++ {
-			freq[X.At(j, i)]++
-		}
-		for j := 0; j < xRows; j++ {
-			densityTable[j] += float64(freq[X.At(j, i)]) / float64(xCols)
-		}
-	}
-	for k := 0; k < xRows; k++ {
-		densityTable[k] = densityTable[k] / float64(xRows)
-		if densityTable[k] > maxDensity {
-			maxDensity = densityTable[k]
-			highestDensityIndex = k
-		}
-	}
+	densityTable, highestDensityIndex := caoDensity(X, weights)
 
 	// Choose first cluster - vector with maximum density.
-	centroids.SetRow(0, X.RawRowView(highestDensityIndex))
+	row := make([]float64, xCols)
+	centroids.SetRow(0, X.RowTo(row, highestDensityIndex))
 
 	// Find the rest of clusters centers.
 	for i := 1; i < clustersNumber; i++ {
@@ -78,7 +130,7 @@ func InitCao(X *DenseMatrix, clustersNumber int, distFunc DistanceFunction) (*De
 		}
 		for j := 0; j < i; j++ {
 			for k := 0; k < xRows; k++ {
-				dist, err := distFunc(&DenseVector{X.RowView(k).(*mat.VecDense)}, &DenseVector{centroids.RowView(j).(*mat.VecDense)})
+				dist, err := rawDist(X.RowTo(row, k), centroids.RawRowView(j))
 				if err != nil {
 					return NewDenseMatrix(0, 0, nil), fmt.Errorf("cao init: cannot compute cluster: %v ", err)
 				}
@@ -88,12 +140,41 @@ func InitCao(X *DenseMatrix, clustersNumber int, distFunc DistanceFunction) (*De
 
 		indexMax := findIndexCao(xRows, i, dd)
 
-		centroids.SetRow(i, X.RawRowView(indexMax))
+		centroids.SetRow(i, X.RowTo(row, indexMax))
 	}
 
 	return centroids, nil
 }
 
+// caoDensity computes the density of every row of X, the average over
+// attributes of the relative frequency of the row's value, and finds the first
+// row with the highest density. Frequencies are sums of weights of rows if
+// weights is not nil.
+func caoDensity(X Matrix, weights []float64) ([]float64, int) {
+	xRows, xCols := X.Dims()
+	total := totalSampleWeight(weights, xRows)
+	highestDensityIndex := 0
+	maxDensity := 0.0
+	densityTable := make([]float64, xRows)
+	for i := 0; i < xCols; i++ {
+		freq := make(map[float64]float64)
+		for j := 0; j < xRows; j++ {
+			freq[X.At(j, i)] += sampleWeight(weights, j)
+		}
+		for j := 0; j < xRows; j++ {
+			densityTable[j] += freq[X.At(j, i)] / float64(xCols)
+		}
+	}
+	for k := 0; k < xRows; k++ {
+		densityTable[k] = densityTable[k] / total
+		if densityTable[k] > maxDensity {
+			maxDensity = densityTable[k]
+			highestDensityIndex = k
+		}
+	}
+	return densityTable, highestDensityIndex
+}
+
 func findIndexCao(xRows, i int, dd [][]float64) int {
 	// Find minimum value for each column.
 	minValuesTable := make([]float64, xRows)
@@ -123,26 +204,26 @@ func findIndexCao(xRows, i int, dd [][]float64) int {
 }
 
 // InitRandom randomly initializes cluster centers - vectors chosen from X table.
-func InitRandom(X *DenseMatrix, clustersNumber int, distFunc DistanceFunction) (*DenseMatrix, error) {
+func InitRandom(X Matrix, clustersNumber int, distFunc DistanceFunction, rnd *rand.Rand) (*DenseMatrix, error) {
 	xRows, xCols := X.Dims()
 	centroids := NewDenseMatrix(clustersNumber, xCols, nil)
-	rand.Seed(time.Now().UnixNano())
 
+	row := make([]float64, xCols)
 	for i := 0; i < clustersNumber; i++ {
-		centroids.SetRow(i, X.RawRowView(rand.Intn(xRows)))
+		centroids.SetRow(i, X.RowTo(row, rnd.Intn(xRows)))
 	}
 	return centroids, nil
 }
 
 // InitNum initializes cluster centers for numerical data - random
 // initialization.
-func InitNum(X *DenseMatrix, clustersNumber int, distFunc DistanceFunction) (*DenseMatrix, error) {
+func InitNum(X Matrix, clustersNumber int, distFunc DistanceFunction, rnd *rand.Rand) (*DenseMatrix, error) {
 	xRows, xCols := X.Dims()
 	centroids := NewDenseMatrix(clustersNumber, xCols, nil)
-	rand.Seed(time.Now().UnixNano())
 
+	row := make([]float64, xCols)
 	for i := 0; i < clustersNumber; i++ {
-		center := X.RawRowView(rand.Intn(xRows - 1))
+		center := X.RowTo(row, rnd.Intn(xRows-1))
 		centroids.SetRow(i, center)
 	}
 	return centroids, nil
@@ -150,21 +231,146 @@ func InitNum(X *DenseMatrix, clustersNumber int, distFunc DistanceFunction) (*De
 
 // CreateFrequencyTable creates frequency table for attributes in given matrix,
 // it returns attributes in frequency descending order.
-func CreateFrequencyTable(X *DenseMatrix) [][]KV {
+func CreateFrequencyTable(X Matrix) [][]KV {
 	xRows, xCols := X.Dims()
 	frequencyTable := make([][]KV, xCols)
 	for i := 0; i < xCols; i++ {
-		column := X.ColView(i)
 		frequencies := make(map[float64]float64)
 		for j := 0; j < xRows; j++ {
-			frequencies[column.At(j, 0)] = frequencies[column.At(j, 0)] + 1
+			frequencies[X.At(j, i)] = frequencies[X.At(j, i)] + 1
 		}
 		for k, v := range frequencies {
 			frequencyTable[i] = append(frequencyTable[i], KV{k, v})
 		}
 		sort.Slice(frequencyTable[i], func(a, b int) bool {
+			if frequencyTable[i][a].Value == frequencyTable[i][b].Value {
+				return frequencyTable[i][a].Key < frequencyTable[i][b].Key
+			}
 			return frequencyTable[i][a].Value > frequencyTable[i][b].Value
 		})
 	}
 	return frequencyTable
 }
+
+// InitCaoMixed implements initialization of prototypes for mixed data. It
+// follows the method of F.Cao(2009): density of records is computed from
+// categorical attributes, but distances between records are computed with the
+// combined k-prototypes distance (gamma*categorical + numerical). Chosen
+// prototypes are whole records, so categorical and numerical parts of each
+// centroid come from the same row.
+func InitCaoMixed(xCat, xNum *DenseMatrix, clustersNumber int, gamma float64, distFunc DistanceFunction, rnd *rand.Rand) (*DenseMatrix, *DenseMatrix, error) {
+	return initCaoMixed(xCat, xNum, clustersNumber, gamma, RawDistance(distFunc), nil)
+}
+
+// initCaoMixed implements InitCaoMixed with a raw distance, frequencies of
+// attribute values are sums of weights of rows if weights is not nil.
+func initCaoMixed(xCat, xNum *DenseMatrix, clustersNumber int, gamma float64, rawDist RawDistanceFunction, weights []float64) (*DenseMatrix, *DenseMatrix, error) {
+	xRows, xCatCols := xCat.Dims()
+	_, xNumCols := xNum.Dims()
+	indexes := make([]int, 0, clustersNumber)
+	densityTable, highestDensityIndex := caoDensity(xCat, weights)
+
+	// Choose first prototype - record with maximum density.
+	indexes = append(indexes, highestDensityIndex)
+
+	// Find the rest of prototypes.
+	for i := 1; i < clustersNumber; i++ {
+		dd := make([][]float64, i)
+		for j := 0; j < i; j++ {
+			dd[j] = make([]float64, xRows)
+			for k := 0; k < xRows; k++ {
+				dist, err := mixedDistance(xCat, xNum, k, indexes[j], gamma, rawDist)
+				if err != nil {
+					return NewDenseMatrix(0, 0, nil), NewDenseMatrix(0, 0, nil), fmt.Errorf("cao mixed init: cannot compute cluster: %v ", err)
+				}
+				dd[j][k] = densityTable[k] * dist
+			}
+		}
+
+		indexes = append(indexes, findIndexCao(xRows, i, dd))
+	}
+
+	centroidsCat := NewDenseMatrix(clustersNumber, xCatCols, nil)
+	centroidsNum := NewDenseMatrix(clustersNumber, xNumCols, nil)
+	for i, index := range indexes {
+		centroidsCat.SetRow(i, xCat.RawRowView(index))
+		centroidsNum.SetRow(i, xNum.RawRowView(index))
+	}
+	return centroidsCat, centroidsNum, nil
+}
+
+// InitPlusPlusMixed implements k-means++ style initialization of prototypes for
+// mixed data. The first prototype is a randomly chosen record, each next one
+// is drawn with probability proportional to the squared combined distance to
+// the nearest prototype already chosen.
+func InitPlusPlusMixed(xCat, xNum *DenseMatrix, clustersNumber int, gamma float64, distFunc DistanceFunction, rnd *rand.Rand) (*DenseMatrix, *DenseMatrix, error) {
+	return initPlusPlusMixed(xCat, xNum, clustersNumber, gamma, RawDistance(distFunc), rnd)
+}
+
+// initPlusPlusMixed implements InitPlusPlusMixed with a raw distance.
+func initPlusPlusMixed(xCat, xNum *DenseMatrix, clustersNumber int, gamma float64, rawDist RawDistanceFunction, rnd *rand.Rand) (*DenseMatrix, *DenseMatrix, error) {
+	xRows, xCatCols := xCat.Dims()
+	_, xNumCols := xNum.Dims()
+	indexes := make([]int, 0, clustersNumber)
+
+	indexes = append(indexes, rnd.Intn(xRows))
+
+	// Squared distances to the nearest chosen prototype.
+	nearest := make([]float64, xRows)
+	for k := range nearest {
+		nearest[k] = math.MaxFloat64
+	}
+
+	for i := 1; i < clustersNumber; i++ {
+		var sum float64
+		for k := 0; k < xRows; k++ {
+			dist, err := mixedDistance(xCat, xNum, k, indexes[i-1], gamma, rawDist)
+			if err != nil {
+				return NewDenseMatrix(0, 0, nil), NewDenseMatrix(0, 0, nil), fmt.Errorf("k-means++ mixed init: cannot compute cluster: %v ", err)
+			}
+			if dist*dist < nearest[k] {
+				nearest[k] = dist * dist
+			}
+			sum += nearest[k]
+		}
+
+		// All records are equal to chosen prototypes - pick any of them.
+		if sum == 0 {
+			indexes = append(indexes, rnd.Intn(xRows))
+			continue
+		}
+
+		index := xRows - 1
+		target := rnd.Float64() * sum
+		for k := 0; k < xRows; k++ {
+			target -= nearest[k]
+			if target < 0 {
+				index = k
+				break
+			}
+		}
+		indexes = append(indexes, index)
+	}
+
+	centroidsCat := NewDenseMatrix(clustersNumber, xCatCols, nil)
+	centroidsNum := NewDenseMatrix(clustersNumber, xNumCols, nil)
+	for i, index := range indexes {
+		centroidsCat.SetRow(i, xCat.RawRowView(index))
+		centroidsNum.SetRow(i, xNum.RawRowView(index))
+	}
+	return centroidsCat, centroidsNum, nil
+}
+
+// mixedDistance computes the k-prototypes distance between records a and b
+// of the partitioned dataset.
+func mixedDistance(xCat, xNum *DenseMatrix, a, b int, gamma float64, distFunc RawDistanceFunction) (float64, error) {
+	distCat, err := distFunc(xCat.RawRowView(a), xCat.RawRowView(b))
+	if err != nil {
+		return -1, err
+	}
+	distNum, err := RawEuclideanDistance(xNum.RawRowView(a), xNum.RawRowView(b))
+	if err != nil {
+		return -1, err
+	}
+	return gamma*distCat + distNum, nil
+}
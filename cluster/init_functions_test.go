@@ -1,6 +1,7 @@
 package cluster
 
 import (
+	"math/rand"
 	"reflect"
 	"sort"
 	"testing"
@@ -77,7 +78,7 @@ func TestInitHuang(t *testing.T) {
 	}
 	for _, tt := range tests {
 
-		got, err := InitHuang(tt.args.X, tt.args.clustersNumber, tt.args.distFunc)
+		got, err := InitHuang(tt.args.X, tt.args.clustersNumber, tt.args.distFunc, rand.New(rand.NewSource(1)))
 		got = sortMatrix(got)
 		if (err != nil) != tt.wantErr {
 			t.Errorf("InitHuang() error = %v, wantErr %v", err, tt.wantErr)
@@ -89,3 +90,105 @@ func TestInitHuang(t *testing.T) {
 
 	}
 }
+
+func TestInitHuangDistinct(t *testing.T) {
+	X := NewDenseMatrix(8, 3, []float64{
+		1, 1, 1,
+		1, 1, 1,
+		1, 1, 2,
+		1, 2, 2,
+		2, 2, 2,
+		2, 2, 2,
+		3, 1, 2,
+		3, 3, 3,
+	})
+
+	for seed := int64(0); seed < 20; seed++ {
+		got, err := InitHuang(X, 4, HammingDistance, rand.New(rand.NewSource(seed)))
+		if err != nil {
+			t.Fatalf("InitHuang() error = %v", err)
+		}
+		for i := 0; i < 4; i++ {
+			var record bool
+			for k := 0; k < 8; k++ {
+				if reflect.DeepEqual(got.RawRowView(i), X.RawRowView(k)) {
+					record = true
+				}
+			}
+			if !record {
+				t.Errorf("seed %d: InitHuang() centroid %v is not a record", seed, got.RawRowView(i))
+			}
+			for j := 0; j < i; j++ {
+				if reflect.DeepEqual(got.RawRowView(i), got.RawRowView(j)) {
+					t.Errorf("seed %d: InitHuang() centroids %d and %d are equal: %v", seed, i, j, got.RawRowView(i))
+				}
+			}
+		}
+	}
+}
+
+func TestInitCaoMixed(t *testing.T) {
+	xCat := NewDenseMatrix(6, 1, []float64{1, 1, 1, 2, 2, 2})
+	xNum := NewDenseMatrix(6, 1, []float64{0.1, 0.2, 0.1, 0.9, 1, 0.9})
+
+	gotCat, gotNum, err := InitCaoMixed(xCat, xNum, 2, 1, HammingDistance, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("InitCaoMixed() error = %v", err)
+	}
+	wantCat := NewDenseMatrix(2, 1, []float64{1, 2})
+	wantNum := NewDenseMatrix(2, 1, []float64{0.1, 1})
+	if !reflect.DeepEqual(gotCat, wantCat) {
+		t.Errorf("InitCaoMixed() cat = %v, want %v", gotCat.Dense, wantCat.Dense)
+	}
+	if !reflect.DeepEqual(gotNum, wantNum) {
+		t.Errorf("InitCaoMixed() num = %v, want %v", gotNum.Dense, wantNum.Dense)
+	}
+}
+
+func TestInitPlusPlusMixed(t *testing.T) {
+	xCat := NewDenseMatrix(4, 1, []float64{1, 1, 2, 2})
+	xNum := NewDenseMatrix(4, 1, []float64{0.1, 0.2, 0.3, 0.4})
+
+	for n := int64(0); n < 10; n++ {
+		gotCat, gotNum, err := InitPlusPlusMixed(xCat, xNum, 3, 1, HammingDistance, rand.New(rand.NewSource(n)))
+		if err != nil {
+			t.Fatalf("InitPlusPlusMixed() error = %v", err)
+		}
+		for i := 0; i < 3; i++ {
+			var found bool
+			for j := 0; j < 4; j++ {
+				if gotCat.At(i, 0) == xCat.At(j, 0) && gotNum.At(i, 0) == xNum.At(j, 0) {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("InitPlusPlusMixed() prototype %d = (%v, %v) is not a record", i, gotCat.At(i, 0), gotNum.At(i, 0))
+			}
+		}
+	}
+}
+
+func TestInitSeed(t *testing.T) {
+	X := NewDenseMatrix(10, 2, []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20})
+	tests := []struct {
+		name string
+		init InitializationFunction
+	}{
+		{name: "InitRandom", init: InitRandom},
+		{name: "InitNum", init: InitNum},
+		{name: "InitHuang", init: InitHuang},
+	}
+	for _, tt := range tests {
+		got1, err := tt.init(X, 3, HammingDistance, rand.New(rand.NewSource(42)))
+		if err != nil {
+			t.Fatalf("%s() error = %v", tt.name, err)
+		}
+		got2, err := tt.init(X, 3, HammingDistance, rand.New(rand.NewSource(42)))
+		if err != nil {
+			t.Fatalf("%s() error = %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got1, got2) {
+			t.Errorf("%s() with the same seed = %v and %v", tt.name, got1.Dense, got2.Dense)
+		}
+	}
+}
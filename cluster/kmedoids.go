@@ -0,0 +1,139 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// KMedoids is a basic class for the k-medoids algorithm working on a
+// precomputed distance matrix. Cluster centers (medoids) are always rows of the
+// dataset, so any distance may be used.
+type KMedoids struct {
+	ClustersNumber     int
+	MaxIterationNumber int
+	Medoids            []int // indexes of rows which are cluster centers
+	LabelsCounter      []int
+	Labels             *DenseVector
+	Cost               float64 // sum of distances of rows to their medoids
+	Iterations         int
+	IsFitted           bool
+}
+
+// NewKMedoids implements constructor for the KMedoids struct.
+func NewKMedoids(clusters int, iters int) *KMedoids {
+	return &KMedoids{
+		ClustersNumber:     clusters,
+		MaxIterationNumber: iters,
+	}
+}
+
+// FitDistances finds medoids for the dataset described by distance matrix D.
+// Initial medoids are chosen with the BUILD step of PAM, then medoids are
+// refined by alternating assignment of rows and choice of the most central
+// row of each cluster.
+func (km *KMedoids) FitDistances(D DistanceMatrix) error {
+	n := D.Len()
+	if km.ClustersNumber < 1 || km.MaxIterationNumber < 1 {
+		return errors.New("kmedoids: wrong initialization parameters (should be >1)")
+	}
+	if n < km.ClustersNumber {
+		return fmt.Errorf("kmedoids: cannot find %d clusters among %d rows", km.ClustersNumber, n)
+	}
+	km.IsFitted = false
+	km.Medoids = buildMedoids(D, km.ClustersNumber)
+	km.Labels = NewDenseVector(n, nil)
+
+	for i := 0; i < km.MaxIterationNumber; i++ {
+		km.Iterations = i + 1
+		km.assign(D)
+		members := make([][]int, km.ClustersNumber)
+		for j := 0; j < n; j++ {
+			label := int(km.Labels.At(j, 0))
+			members[label] = append(members[label], j)
+		}
+
+		var change bool
+		for c := range km.Medoids {
+			best, bestCost := km.Medoids[c], math.MaxFloat64
+			for _, j := range members[c] {
+				var cost float64
+				for _, l := range members[c] {
+					cost += D.Distance(j, l)
+				}
+				if cost < bestCost {
+					best, bestCost = j, cost
+				}
+			}
+			if best != km.Medoids[c] {
+				km.Medoids[c] = best
+				change = true
+			}
+		}
+		if !change {
+			km.IsFitted = true
+			break
+		}
+	}
+	km.assign(D)
+	return nil
+}
+
+// assign sets labels of all rows to their nearest medoids.
+func (km *KMedoids) assign(D DistanceMatrix) {
+	km.LabelsCounter = make([]int, km.ClustersNumber)
+	km.Cost = 0
+	for j := 0; j < D.Len(); j++ {
+		label, dist := 0, math.MaxFloat64
+		for c, m := range km.Medoids {
+			if d := D.Distance(j, m); d < dist {
+				label, dist = c, d
+			}
+		}
+		km.Labels.SetVec(j, float64(label))
+		km.LabelsCounter[label]++
+		km.Cost += dist
+	}
+}
+
+// buildMedoids chooses initial medoids with the BUILD step of PAM: the first
+// medoid minimizes sum of distances to all rows, each next one gives the
+// largest decrease of the total cost.
+func buildMedoids(D DistanceMatrix, k int) []int {
+	n := D.Len()
+	medoids := make([]int, 0, k)
+	isMedoid := make([]bool, n)
+
+	nearest := make([]float64, n)
+	for j := range nearest {
+		nearest[j] = math.MaxFloat64
+	}
+	for len(medoids) < k {
+		best, bestGain := -1, -math.MaxFloat64
+		for c := 0; c < n; c++ {
+			if isMedoid[c] {
+				continue
+			}
+			var gain float64
+			for j := 0; j < n; j++ {
+				d := D.Distance(j, c)
+				if len(medoids) == 0 {
+					gain -= d
+				} else if d < nearest[j] {
+					gain += nearest[j] - d
+				}
+			}
+			if gain > bestGain {
+				best, bestGain = c, gain
+			}
+		}
+		medoids = append(medoids, best)
+		isMedoid[best] = true
+		for j := 0; j < n; j++ {
+			if d := D.Distance(j, best); d < nearest[j] {
+				nearest[j] = d
+			}
+		}
+	}
+	return medoids
+}
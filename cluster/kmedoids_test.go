@@ -0,0 +1,43 @@
+package cluster
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKMedoids_FitDistances(t *testing.T) {
+	// Two well separated groups of points on a line.
+	X := NewDenseMatrix(7, 1, []float64{1, 2, 3, 10, 11, 12, 13})
+	D, err := ComputeDistanceMatrix(X, EuclideanDistance, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		km         *KMedoids
+		wantLabels []float64
+		wantErr    bool
+	}{
+		{km: NewKMedoids(2, 10), wantLabels: []float64{1, 1, 1, 0, 0, 0, 0}},
+		{km: NewKMedoids(0, 10), wantErr: true},
+		{km: NewKMedoids(8, 10), wantErr: true},
+	}
+	for i, tt := range tests {
+		err := tt.km.FitDistances(D)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%d. KMedoids.FitDistances() error = %v, wantErr %v", i, err, tt.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if !tt.km.IsFitted {
+			t.Errorf("%d. KMedoids.FitDistances() did not converge", i)
+		}
+		if got := tt.km.Labels.RawVector().Data; !reflect.DeepEqual(got, tt.wantLabels) {
+			t.Errorf("%d. KMedoids.Labels = %v, want %v", i, got, tt.wantLabels)
+		}
+		if tt.km.Cost != 6 {
+			t.Errorf("%d. KMedoids.Cost = %v, want 6", i, tt.km.Cost)
+		}
+	}
+}
@@ -15,8 +15,25 @@ import (
 // DistanceFunction compute distance between two vectors.
 type DistanceFunction func(a, b *DenseVector) (float64, error)
 
-// InitializationFunction compute initial vales for cluster_centroids_.
-type InitializationFunction func(X *DenseMatrix, clustersNumber int, distFunc DistanceFunction) (*DenseMatrix, error)
+// InitializationFunction compute initial vales for cluster_centroids_. Any
+// randomness must be drawn from rnd, so that fits with the same seed are
+// reproducible.
+type InitializationFunction func(X Matrix, clustersNumber int, distFunc DistanceFunction, rnd *rand.Rand) (*DenseMatrix, error)
+
+// TieBreak defines which value becomes the mode of an attribute when several
+// values are equally frequent in a cluster.
+type TieBreak int
+
+const (
+	// TieBreakSmallest chooses the smallest of tied values.
+	TieBreakSmallest TieBreak = iota
+	// TieBreakPrevious keeps the value of the previous centroid if it is among
+	// tied values, otherwise the smallest one is chosen.
+	TieBreakPrevious
+	// TieBreakGlobalFrequency chooses the tied value which is the most frequent
+	// in the whole dataset, remaining ties are resolved with the smallest value.
+	TieBreakGlobalFrequency
+)
 
 // KModes is a basic class for the k-modes algorithm, it contains all necessary
 // information as alg. parameters, labels, centroids, ...
@@ -29,15 +46,48 @@ type KModes struct {
 	WeightVectors      [][]float64
 	FrequencyTable     [][]map[float64]float64 // frequency table - list of lists with dictionaries containing frequencies of values per cluster and attribute
 	LabelsCounter      []int
+	ClusterWeights     []float64 // sums of sample weights of cluster members, equal to LabelsCounter without weights
 	Labels             *DenseVector
 	ClusterCentroids   *DenseMatrix
 	IsFitted           bool
 	ModelPath          string
+	Seed               int64                // seed of the random source used by initialization and fitting
+	TieBreakPolicy     TieBreak             // how modes are chosen among equally frequent values
+	EmptyClusterAction EmptyClusterStrategy // what to do with clusters which lost all members
+	DroppedClusters    []int                // clusters dropped during fitting with EmptyClusterDrop
+	CostTolerance      float64              // stop when relative cost improvement is below this value
+	MovedTolerance     float64              // stop when fraction of rows (by weight) changing cluster is below this value
+	TimeLimit          time.Duration        // stop when fitting takes longer, the model is fitted with StopReason StopTimeLimit
+	StopReason         StopReason           // rule which ended the last fit
+	Iterations         int                  // number of iterations done by the last fit
+	Cost               float64              // cost of the last iteration
+	DistanceQuantiles  [][]float64          // per cluster quantiles of members' distances to the centroid at levels 0, 0.01, ..., 1, recorded by FitModel
+	NoveltyQuantile    float64              // in (0, 1], if set, Predict labels rows farther from the centroid than this quantile of members' distances with NoveltyLabel; 0 disables it
+	CompressRows       bool                 // fit on unique rows weighted by their counts, labels are expanded back to all rows
+	Constraints        *Constraints         // if set, the assignment step honors must-link and cannot-link constraints of rows
+	Violations         []Violation          // constraints not satisfied by Labels after the last fit with Constraints
+	MinClusterSize     int                  // if set, every cluster keeps at least this number of rows
+	MaxClusterSize     int                  // if set, no cluster gets more than this number of rows
+	InitialCentroids   [][]float64          // if set, fitting starts from these centroids instead of InitializationFunc
+	FixedClusters      []int                // clusters which keep their InitialCentroids during fitting
+
+	rnd             *rand.Rand
+	rawDist         RawDistanceFunction
+	packed          *packedEncoder // bit packed rows are used for Hamming distances during fitting
+	packedRows      []uint64
+	packedCentroids []uint64
+	rowBuf          []float64 // buffer for rows of matrices which are not stored as float64
+	globalFrequency []map[float64]float64
+	sampleWeights   []float64    // weights of rows of the fitted dataset, nil if rows are not weighted
+	compressed      *compression // original rows of a fit with CompressRows
+	constraints     *constraintSet
+	distBuf         []float64 // buffer for distances of a row to all clusters
+	balanced        []int     // clusters of rows chosen for the current pass when cluster sizes are limited
+	balancedDist    []float64 // distances of rows to balanced clusters
 }
 
 // NewKModes implements constructor for the KModes struct.
 func NewKModes(dist DistanceFunction, init InitializationFunction, clusters int, runs int, iters int, weights [][]float64, modelPath string) *KModes {
-	rand.Seed(time.Now().UnixNano())
 	return &KModes{
 		DistanceFunc:       dist,
 		InitializationFunc: init,
@@ -46,33 +96,168 @@ func NewKModes(dist DistanceFunction, init InitializationFunction, clusters int,
 		MaxIterationNumber: iters,
 		WeightVectors:      weights,
 		ModelPath:          modelPath,
+		Seed:               time.Now().UnixNano(),
 		Labels:             &DenseVector{VecDense: new(mat.VecDense)},
 		ClusterCentroids:   &DenseMatrix{Dense: new(mat.Dense)},
 	}
 }
 
 // FitModel main algorithm function which finds the best clusters centers for
-// the given dataset X.
-//func (km *KModes) FitModel(X *mat.Dense) error {
-func (km *KModes) FitModel(X *DenseMatrix) error {
+// the given dataset X, which may be a DenseMatrix or a compact
+// CategoricalMatrix.
+//
+// If CompressRows is set, identical rows are collapsed into unique rows
+// weighted by their counts after initialization, which runs on all rows. The
+// result is the same as without compression, empty clusters are refilled with
+// single copies of rows chosen among all rows.
+//
+// If Constraints is set, rows are assigned as in COP-k-modes: every row joins
+// the nearest cluster which does not break constraints with rows assigned
+// before it in the same pass. When there is no such cluster, the row joins the
+// nearest one and the broken constraints are reported in Violations.
+//
+// If MinClusterSize or MaxClusterSize is set, every pass first finds the
+// nearest clusters of all rows, then rows are transferred greedily, cheapest
+// increase of distance first, out of too large clusters and into too small
+// ones. Limits apply to numbers of rows and cannot be combined with
+// Constraints or CompressRows.
+//
+// If InitialCentroids is set, e.g. to CentroidRows of a previous model, it is
+// used instead of InitializationFunc. Centroids of FixedClusters are not
+// updated, such clusters only gather rows and are never refilled or dropped
+// when empty.
+func (km *KModes) FitModel(X Matrix) error {
+	return km.fit(X, nil)
+}
+
+// FitModelWeighted fits the model like FitModel, row i of X counts as
+// weights[i] rows. Frequency tables, ClusterWeights, cost and distance
+// quantiles are weighted, so integer weights give the same model as repeating
+// rows. Densities of InitCao are weighted too, other initializations ignore
+// weights. Weights must be positive.
+func (km *KModes) FitModelWeighted(X Matrix, weights []float64) error {
+	xRows, _ := X.Dims()
+	if err := checkSampleWeights(weights, xRows); err != nil {
+		return fmt.Errorf("kmodes: failed to fit the model: %v", err)
+	}
+	return km.fit(X, weights)
+}
+
+func (km *KModes) fit(X Matrix, weights []float64) error {
+	start := time.Now()
 	err := km.validateParameters()
 	if err != nil {
 		return fmt.Errorf("kmodes: failed to fit the model: %v", err)
 	}
-	// Initialize weightVector
-	SetWeights(km.WeightVectors[0])
+	_, xCols := X.Dims()
+	if err := checkInitialCentroids(km.InitialCentroids, km.FixedClusters, km.ClustersNumber, xCols); err != nil {
+		return fmt.Errorf("kmodes: failed to fit the model: %v", err)
+	}
+
+	km.Violations = nil
+	if km.MinClusterSize > 0 || km.MaxClusterSize > 0 {
+		if km.Constraints != nil || km.CompressRows {
+			return errors.New("kmodes: failed to fit the model: cluster size limits cannot be used with constraints or compressed rows")
+		}
+		xRows, _ := X.Dims()
+		if err := checkClusterSizes(km.MinClusterSize, km.MaxClusterSize, km.ClustersNumber, xRows); err != nil {
+			return fmt.Errorf("kmodes: failed to fit the model: %v", err)
+		}
+		defer func() {
+			km.balanced, km.balancedDist = nil, nil
+		}()
+	}
+	if km.Constraints != nil {
+		if km.CompressRows {
+			return errors.New("kmodes: failed to fit the model: constraints cannot be used with compressed rows")
+		}
+		xRows, _ := X.Dims()
+		km.constraints, err = newConstraintSet(km.Constraints, xRows)
+		if err != nil {
+			return fmt.Errorf("kmodes: failed to fit the model: %v", err)
+		}
+		km.distBuf = make([]float64, km.ClustersNumber)
+		defer func() {
+			km.constraints, km.distBuf = nil, nil
+		}()
+	}
+
+	// Initialize random source, global one is never used in order to make
+	// results reproducible.
+	km.rnd = rand.New(rand.NewSource(km.Seed))
 
+	// Initialize clusters on all rows, so that compressed fits start from the
+	// same centroids.
+	if km.InitialCentroids != nil {
+		km.ClusterCentroids = centroidMatrix(km.InitialCentroids)
+	} else {
+		km.ClusterCentroids, err = modelInitialization(km.InitializationFunc, modelDistance(km.DistanceFunc, km.WeightVectors), weights)(X, km.ClustersNumber, boundDistance(km.DistanceFunc, km.WeightVectors), km.rnd)
+		if err != nil {
+			return fmt.Errorf("kmodes: failed to fit the model: %v", err)
+		}
+	}
+
+	if !km.CompressRows {
+		if err := km.fitRows(X, weights, start); err != nil {
+			return err
+		}
+		km.Violations = km.Constraints.violations(km.Labels)
+		return km.recordDistanceQuantiles(X, weights)
+	}
+	unique, uniqueWeights, rows := compressRows(X, weights)
+	km.compressed = newCompression(rows, weights, len(uniqueWeights))
+	defer func() {
+		km.compressed = nil
+	}()
+	if err := km.fitRows(unique, uniqueWeights, start); err != nil {
+		return err
+	}
+	km.expandLabels()
+	return km.recordDistanceQuantiles(X, weights)
+}
+
+// fitRows runs the algorithm on rows of X with initial centroids already set.
+func (km *KModes) fitRows(X Matrix, weights []float64, start time.Time) error {
+	km.sampleWeights = weights
+	defer func() {
+		km.sampleWeights = nil
+	}()
 	xRows, xCols := X.Dims()
+	totalWeight := totalSampleWeight(weights, xRows)
 
-	// Initialize clusters
-	km.ClusterCentroids, err = km.InitializationFunc(X, km.ClustersNumber, km.DistanceFunc)
-	if err != nil {
-		return fmt.Errorf("kmodes: failed to fit the model: %v", err)
+	km.rawDist = modelDistance(km.DistanceFunc, km.WeightVectors)
+	km.rowBuf = make([]float64, xCols)
+
+	// Hamming distances are computed on bit packed rows.
+	if isPackable(km.DistanceFunc) {
+		var attrWeights []float64
+		if isWeighted(km.DistanceFunc) {
+			attrWeights = km.WeightVectors[0]
+		}
+		if attrWeights == nil || len(attrWeights) == xCols {
+			// Packing is skipped if it would need more memory than X.
+			if e := newPackedEncoder(X, attrWeights); e.fits(X) {
+				km.packed = e
+				km.packedRows = e.encodeMatrix(X)
+				km.packedCentroids = make([]uint64, km.ClustersNumber*e.words)
+				defer func() {
+					km.packed, km.packedRows, km.packedCentroids = nil, nil, nil
+				}()
+			}
+		}
+	}
+
+	if km.TieBreakPolicy == TieBreakGlobalFrequency {
+		km.globalFrequency = columnFrequencies(X, weights)
 	}
 
 	// Initialize labels vector
 	km.Labels = NewDenseVector(xRows, nil)
 	km.LabelsCounter = make([]int, km.ClustersNumber)
+	km.ClusterWeights = make([]float64, km.ClustersNumber)
+	km.DroppedClusters = nil
+	km.StopReason = StopNone
+	km.IsFitted = false
 
 	// Create frequency table
 	km.FrequencyTable = make([][]map[float64]float64, km.ClustersNumber)
@@ -85,20 +270,36 @@ func (km *KModes) FitModel(X *DenseMatrix) error {
 
 	// Perform initial assignements to clusters - in order to fill in frequency
 	// table.
+	costs := make([]float64, xRows)
+	km.packCentroids()
+	if err := km.balance(X); err != nil {
+		return fmt.Errorf("kmodes: initial labels assignement failure: %v", err)
+	}
 	for i := 0; i < xRows; i++ {
-		row := X.RowView(i)
-		newLabel, _, err := km.near(i, &DenseVector{X.RowView(i).(*mat.VecDense)})
-		km.LabelsCounter[int(newLabel)]++
+		newLabel, cost, err := km.assign(X, i)
+		row := X.RowTo(km.rowBuf, i)
+		w := sampleWeight(weights, i)
+		costs[i] = w * cost
+		km.compressed.setDistance(i, cost)
+		km.LabelsCounter[int(newLabel)] += km.compressed.count(i)
+		km.ClusterWeights[int(newLabel)] += w
 		km.Labels.SetVec(i, newLabel)
 		if err != nil {
 			return fmt.Errorf("kmodes: initial labels assignement failure: %v", err)
 		}
 		for j := 0; j < xCols; j++ {
-			km.FrequencyTable[int(newLabel)][j][row.At(j, 0)]++
+			km.FrequencyTable[int(newLabel)][j][row[j]] += w
 		}
 
 	}
 
+	// Initial centroids may leave some clusters empty.
+	km.handleEmptyClusters(X, costs, make([]bool, km.ClustersNumber))
+	var prevCost float64
+	for _, cost := range costs {
+		prevCost += cost
+	}
+
 	// Perform initial centers update - because iteration() starts with label
 	// assignements.
 	for i := 0; i < km.ClustersNumber; i++ {
@@ -106,25 +307,58 @@ func (km *KModes) FitModel(X *DenseMatrix) error {
 	}
 
 	for i := 0; i < km.MaxIterationNumber; i++ {
-		_, change, err := km.iteration(X)
+		cost, moved, err := km.iteration(X)
 		if err != nil {
 			return fmt.Errorf("KMeans error at iteration %d: %v", i, err)
 		}
-		if !change {
-
+		km.Iterations = i + 1
+		km.Cost = cost
+		if reason, stop := stopRule(prevCost, cost, moved, totalWeight, km.CostTolerance, km.MovedTolerance, start, km.TimeLimit); stop {
+			km.StopReason = reason
 			km.IsFitted = true
 			return nil
 		}
+		prevCost = cost
 	}
+	// Centroids are usable when the iteration budget runs out too,
+	// StopReason tells whether they are stable.
+	km.StopReason = StopMaxIterations
+	km.IsFitted = true
+
+	return nil
+}
 
+// recordDistanceQuantiles stores quantiles of distances of rows of X to their
+// cluster centroids in DistanceQuantiles, weighted by sample weights. The raw
+// distance is used even if rows are packed, so that Predict compares exactly
+// the same values.
+func (km *KModes) recordDistanceQuantiles(X Matrix, weights []float64) error {
+	xRows, _ := X.Dims()
+	distances := make([]float64, xRows)
+	for i := 0; i < xRows; i++ {
+		label := int(km.Labels.At(i, 0))
+		d, err := km.rawDist(X.RowTo(km.rowBuf, i), km.ClusterCentroids.RawRowView(label))
+		if err != nil {
+			return fmt.Errorf("kmodes: cannot compute distance quantiles: %v", err)
+		}
+		distances[i] = d
+	}
+	km.DistanceQuantiles = distanceQuantiles(km.Labels, distances, weights, km.ClustersNumber)
 	return nil
 }
 
 func (km *KModes) findNewCenters(i, xCols int) {
+	if isFixed(km.FixedClusters, i) {
+		return
+	}
 
 	newCentroid := make([]float64, xCols)
 	for j := 0; j < xCols; j++ {
-		val, empty := findHighestMapValue(km.FrequencyTable[i][j])
+		var global map[float64]float64
+		if km.globalFrequency != nil {
+			global = km.globalFrequency[j]
+		}
+		val, empty := findHighestMapValue(km.FrequencyTable[i][j], km.TieBreakPolicy, km.ClusterCentroids.At(i, j), global)
 		if !empty {
 			newCentroid[j] = val
 		} else {
@@ -135,51 +369,44 @@ func (km *KModes) findNewCenters(i, xCols int) {
 	km.ClusterCentroids.SetRow(i, newCentroid)
 }
 
-func (km *KModes) iteration(X *DenseMatrix) (float64, bool, error) {
+func (km *KModes) iteration(X Matrix) (float64, float64, error) {
 	changed := make([]bool, km.ClustersNumber)
-	var change bool
 	var numOfChanges float64
 	var totalCost float64
 
 	// Find closest cluster for all data vectors - assign new labels.
 	xRows, xCols := X.Dims()
+	costs := make([]float64, xRows)
+	km.packCentroids()
+	if err := km.balance(X); err != nil {
+		return totalCost, numOfChanges, fmt.Errorf("iteration error: %v", err)
+	}
 
 	for i := 0; i < xRows; i++ {
-		row := X.RowView(i)
-		newLabel, cost, err := km.near(i, &DenseVector{X.RowView(i).(*mat.VecDense)})
+		newLabel, cost, err := km.assign(X, i)
 		if err != nil {
-			return totalCost, change, fmt.Errorf("iteration error: %v", err)
+			return totalCost, numOfChanges, fmt.Errorf("iteration error: %v", err)
 		}
-		totalCost += cost
+		costs[i] = sampleWeight(km.sampleWeights, i) * cost
+		km.compressed.setDistance(i, cost)
 
 		if newLabel != km.Labels.At(i, 0) {
-			km.LabelsCounter[int(newLabel)]++
-			km.LabelsCounter[int(km.Labels.At(i, 0))]--
-
-			// Make changes in frequency table.
-			for j := 0; j < xCols; j++ {
-				km.FrequencyTable[int(km.Labels.At(i, 0))][j][row.At(j, 0)]--
-				km.FrequencyTable[int(newLabel)][j][row.At(j, 0)]++
-
+			// Copies moved to empty clusters do not follow the label.
+			if n, w := km.compressed.share(i, sampleWeight(km.sampleWeights, i)); n > 0 {
+				numOfChanges += w
+				changed[int(newLabel)] = true
+				changed[int(km.Labels.At(i, 0))] = true
 			}
-			change = true
-
-			numOfChanges++
-			changed[int(newLabel)] = true
-			changed[int(km.Labels.At(i, 0))] = true
-			km.Labels.SetVec(i, newLabel)
+			km.moveRow(X, i, int(newLabel))
 		}
+		numOfChanges += km.rejoinCopies(X, i, int(newLabel), changed)
 
 	}
 
-	// Check for empty clusters - if such cluster is found reassign the center
-	// and return.
-	for i := 0; i < km.ClustersNumber; i++ {
-		if km.LabelsCounter[i] == 0 {
-			vector := X.RawRowView(rand.Intn(xRows))
-			km.ClusterCentroids.SetRow(i, vector)
-			return totalCost, true, nil
-		}
+	// Check for empty clusters.
+	numOfChanges += km.handleEmptyClusters(X, costs, changed)
+	for _, cost := range costs {
+		totalCost += cost
 	}
 
 	// Recompute cluster centers for all clusters with changes.
@@ -190,63 +417,382 @@ func (km *KModes) iteration(X *DenseMatrix) (float64, bool, error) {
 		}
 	}
 
-	return totalCost, change, nil
+	return totalCost, numOfChanges, nil
 }
 
-func (km *KModes) near(index int, vector *DenseVector) (float64, float64, error) {
+// moveRow reassigns row i of X to the cluster newLabel, counters and frequency
+// table are updated accordingly.
+func (km *KModes) moveRow(X Matrix, i, newLabel int) {
+	oldLabel := int(km.Labels.At(i, 0))
+	if n, w := km.compressed.share(i, sampleWeight(km.sampleWeights, i)); n > 0 {
+		km.moveMembers(X.RowTo(km.rowBuf, i), oldLabel, newLabel, n, w)
+	}
+	km.Labels.SetVec(i, float64(newLabel))
+}
+
+// moveMembers moves n rows equal to row with total weight w from cluster
+// oldLabel to newLabel in counters and frequency table.
+func (km *KModes) moveMembers(row []float64, oldLabel, newLabel, n int, w float64) {
+	km.LabelsCounter[newLabel] += n
+	km.LabelsCounter[oldLabel] -= n
+	km.ClusterWeights[newLabel] += w
+	km.ClusterWeights[oldLabel] -= w
+
+	// Make changes in frequency table.
+	for j, v := range row {
+		km.FrequencyTable[oldLabel][j][v] -= w
+		km.FrequencyTable[newLabel][j][v] += w
+	}
+}
+
+// rejoinCopies moves copies of unique row i, which were moved to empty
+// clusters by a compressed fit, to newLabel of the row. Clusters which lose or
+// gain copies are marked in changed. It returns the weight of moved copies.
+func (km *KModes) rejoinCopies(X Matrix, i, newLabel int, changed []bool) float64 {
+	if km.compressed == nil || len(km.compressed.moved[i]) == 0 {
+		return 0
+	}
+	var moved float64
+	row := X.RowTo(km.rowBuf, i)
+	for _, m := range km.compressed.moved[i] {
+		if m.cluster == newLabel {
+			continue
+		}
+		w := sampleWeight(km.compressed.weights, m.row)
+		km.moveMembers(row, m.cluster, newLabel, 1, w)
+		changed[m.cluster] = true
+		changed[newLabel] = true
+		moved += w
+	}
+	delete(km.compressed.moved, i)
+	return moved
+}
+
+// handleEmptyClusters applies EmptyClusterAction to all clusters without
+// members. costs of moved rows are updated and clusters which need centers
+// recomputation are marked in changed. It returns the weight of moved rows.
+func (km *KModes) handleEmptyClusters(X Matrix, costs []float64, changed []bool) float64 {
+	var moved float64
+	for i := 0; i < km.ClustersNumber; i++ {
+		if km.LabelsCounter[i] != 0 || isDropped(km.DroppedClusters, i) || isFixed(km.FixedClusters, i) {
+			continue
+		}
+		if km.EmptyClusterAction == EmptyClusterDrop {
+			km.DroppedClusters = append(km.DroppedClusters, i)
+			continue
+		}
+		if km.compressed != nil {
+			moved += km.refillCompressed(X, i, costs, changed)
+			continue
+		}
+		index, ok := emptyClusterRow(km.EmptyClusterAction, km.rnd, km.Labels, km.LabelsCounter, costs)
+		if !ok {
+			continue
+		}
+		changed[int(km.Labels.At(index, 0))] = true
+		changed[i] = true
+		km.moveRow(X, index, i)
+		km.ClusterCentroids.SetRow(i, X.RowTo(km.rowBuf, index))
+		costs[index] = 0
+		moved += sampleWeight(km.sampleWeights, index)
+	}
+	if km.compressed != nil {
+		km.compressed.labels, km.compressed.costs = nil, nil
+	}
+	return moved
+}
+
+// refillCompressed moves a single copy of a unique row to the empty cluster i
+// during a compressed fit. The copy is chosen among original rows, so that the
+// same row is chosen as without compression. It returns the weight of the
+// copy, which is 0 if there is no row to move.
+func (km *KModes) refillCompressed(X Matrix, i int, costs []float64, changed []bool) float64 {
+	c := km.compressed
+	if c.labels == nil {
+		c.expand(km.Labels)
+	}
+	r, ok := emptyClusterRow(km.EmptyClusterAction, km.rnd, c.labels, km.LabelsCounter, c.costs)
+	if !ok {
+		return 0
+	}
+	u, oldLabel := c.rows[r], int(c.labels.At(r, 0))
+	w := sampleWeight(c.weights, r)
+	row := X.RowTo(km.rowBuf, u)
+	km.moveMembers(row, oldLabel, i, 1, w)
+	km.ClusterCentroids.SetRow(i, row)
+	costs[u] -= c.costs[r]
+	c.costs[r] = 0
+	c.labels.SetVec(r, float64(i))
+	c.moved[u] = append(c.moved[u], movedCopy{row: r, cluster: i})
+	changed[oldLabel] = true
+	changed[i] = true
+	return w
+}
+
+// expandLabels sets labels of rows of the original dataset from labels of
+// their unique rows and of copies moved to empty clusters after a compressed
+// fit.
+func (km *KModes) expandLabels() {
+	c := km.compressed
+	labels := NewDenseVector(len(c.rows), nil)
+	for i, u := range c.rows {
+		labels.SetVec(i, km.Labels.At(u, 0))
+	}
+	for _, copies := range c.moved {
+		for _, m := range copies {
+			labels.SetVec(m.row, float64(m.cluster))
+		}
+	}
+	km.LabelsCounter = make([]int, km.ClustersNumber)
+	for i := range c.rows {
+		km.LabelsCounter[int(labels.At(i, 0))]++
+	}
+	km.Labels = labels
+}
+
+// packCentroids encodes current centroids when packed rows are used.
+func (km *KModes) packCentroids() {
+	if km.packed == nil {
+		return
+	}
+	w := km.packed.words
+	for i := 0; i < km.ClustersNumber; i++ {
+		km.packed.encode(km.ClusterCentroids.RawRowView(i), km.packedCentroids[i*w:(i+1)*w])
+	}
+}
+
+// assign finds the cluster for row i of the fitted dataset, constraints are
+// honored if set.
+func (km *KModes) assign(X Matrix, i int) (float64, float64, error) {
+	if km.balanced != nil {
+		return float64(km.balanced[i]), km.balancedDist[i], nil
+	}
+	if km.constraints == nil {
+		return km.nearFit(X, i)
+	}
+	if err := km.clusterDistances(X, i, km.distBuf); err != nil {
+		return -1, -1, err
+	}
+	label, d := km.constraints.choose(i, km.distBuf, km.Labels)
+	return float64(label), d, nil
+}
+
+// balance chooses clusters of all rows for the next pass if cluster sizes
+// are limited.
+func (km *KModes) balance(X Matrix) error {
+	if km.MinClusterSize == 0 && km.MaxClusterSize == 0 {
+		return nil
+	}
+	xRows, _ := X.Dims()
+	k := km.ClustersNumber
+	distances := make([]float64, xRows*k)
+	km.balanced = make([]int, xRows)
+	km.balancedDist = make([]float64, xRows)
+	for i := 0; i < xRows; i++ {
+		row := distances[i*k : (i+1)*k]
+		if err := km.clusterDistances(X, i, row); err != nil {
+			return err
+		}
+		km.balanced[i] = nearestCluster(row)
+	}
+	balanceLabels(km.balanced, distances, k, km.DroppedClusters, km.MinClusterSize, km.MaxClusterSize)
+	for i, c := range km.balanced {
+		km.balancedDist[i] = distances[i*k+c]
+	}
+	return nil
+}
+
+// clusterDistances computes distances of row i of the fitted dataset to all
+// clusters, +Inf for dropped ones.
+func (km *KModes) clusterDistances(X Matrix, i int, distances []float64) error {
+	row := X.RowTo(km.rowBuf, i)
+	for c := range distances {
+		if isDropped(km.DroppedClusters, c) {
+			distances[c] = math.Inf(1)
+			continue
+		}
+		if km.packed != nil {
+			w := km.packed.words
+			distances[c] = km.packed.distance(km.packedRows[i*w:(i+1)*w], km.packedCentroids[c*w:(c+1)*w])
+			continue
+		}
+		d, err := km.rawDist(row, km.ClusterCentroids.RawRowView(c))
+		if err != nil {
+			return fmt.Errorf("cannot compute nearest cluster for vector %q: %v", i, err)
+		}
+		distances[c] = d
+	}
+	return nil
+}
+
+// nearFit finds the nearest cluster for row i of the fitted dataset X, packed
+// rows are used if available.
+func (km *KModes) nearFit(X Matrix, i int) (float64, float64, error) {
+	if km.packed == nil {
+		return km.near(km.rawDist, i, X.RowTo(km.rowBuf, i))
+	}
+	var newLabel float64
+	distance := math.MaxFloat64
+	w := km.packed.words
+	row := km.packedRows[i*w : (i+1)*w]
+	for c := 0; c < km.ClustersNumber; c++ {
+		if isDropped(km.DroppedClusters, c) {
+			continue
+		}
+		dist := km.packed.distance(row, km.packedCentroids[c*w:(c+1)*w])
+		if dist < distance {
+			distance = dist
+			newLabel = float64(c)
+		}
+	}
+	return newLabel, distance, nil
+}
+
+// near finds the nearest cluster for the vector with the distance dist.
+func (km *KModes) near(dist RawDistanceFunction, index int, vector []float64) (float64, float64, error) {
 	var newLabel, distance float64
 	distance = math.MaxFloat64
 	for i := 0; i < km.ClustersNumber; i++ {
-		dist, err := km.DistanceFunc(vector, &DenseVector{km.ClusterCentroids.RowView(i).(*mat.VecDense)})
+		if isDropped(km.DroppedClusters, i) {
+			continue
+		}
+		d, err := dist(vector, km.ClusterCentroids.RawRowView(i))
 		if err != nil {
 			return -1, -1, fmt.Errorf("cannot compute nearest cluster for vector %q: %v", index, err)
 		}
-		if dist < distance {
-			distance = dist
+		if d < distance {
+			distance = d
 			newLabel = float64(i)
 		}
 	}
 	return newLabel, distance, nil
 }
 
-func findHighestMapValue(m map[float64]float64) (float64, bool) {
+// findHighestMapValue returns the key with the highest value in the map, ties
+// are resolved according to the given policy. previous is the value of the
+// attribute in the current centroid and global holds frequencies of the
+// attribute values in the whole dataset.
+func findHighestMapValue(m map[float64]float64, policy TieBreak, previous float64, global map[float64]float64) (float64, bool) {
 	var key float64
 	var highestValue float64
-
-	// Do something different if map is empty because if its empty it returns
-	// key=0 !!!
-	if len(m) == 0 {
-		return 0, true
-	}
+	var ties int
 
 	for k, value := range m {
+		if value <= 0 {
+			continue
+		}
 		if value > highestValue {
 			highestValue = value
 			key = k
+			ties = 1
+		} else if value == highestValue {
+			ties++
+			if k < key {
+				key = k
+			}
+		}
+	}
 
+	// Do something different if map is empty because if its empty it returns
+	// key=0 !!!
+	if highestValue == 0 {
+		return 0, true
+	}
+	if ties == 1 {
+		return key, false
+	}
+
+	// Key holds the smallest of tied values at this point.
+	switch policy {
+	case TieBreakPrevious:
+		if m[previous] == highestValue {
+			return previous, false
+		}
+	case TieBreakGlobalFrequency:
+		best := global[key]
+		for k, value := range m {
+			if value == highestValue && (global[k] > best || (global[k] == best && k < key)) {
+				best = global[k]
+				key = k
+			}
 		}
 	}
 
 	return key, false
 }
 
-// Predict assign labels for the set of new vectors.
-func (km *KModes) Predict(X *DenseMatrix) (*DenseVector, error) {
+// columnFrequencies computes frequencies of values of every column of X,
+// frequencies are sums of weights of rows if weights is not nil.
+func columnFrequencies(X Matrix, weights []float64) []map[float64]float64 {
+	xRows, xCols := X.Dims()
+	frequencies := make([]map[float64]float64, xCols)
+	for j := 0; j < xCols; j++ {
+		frequencies[j] = make(map[float64]float64)
+		for i := 0; i < xRows; i++ {
+			frequencies[j][X.At(i, j)] += sampleWeight(weights, i)
+		}
+	}
+	return frequencies
+}
+
+// Predict assign labels for the set of new vectors. If NoveltyQuantile is
+// set (to a quantile in (0, 1], 0 means unset), vectors farther from the
+// nearest centroid than this quantile of distances of cluster members get
+// NoveltyLabel. It does not modify the model, so it may be called
+// concurrently.
+func (km *KModes) Predict(X Matrix) (*DenseVector, error) {
 	if !km.IsFitted {
 		return &DenseVector{&mat.VecDense{}}, errors.New("kmodes: cannot predict labels, model is not fitted yet")
 	}
-	xRows, _ := X.Dims()
+	if err := checkNoveltyQuantile(km.NoveltyQuantile, km.DistanceQuantiles); err != nil {
+		return &DenseVector{&mat.VecDense{}}, fmt.Errorf("kmodes Predict: %v", err)
+	}
+	dist := modelDistance(km.DistanceFunc, km.WeightVectors)
+	xRows, xCols := X.Dims()
 	labelsVec := NewDenseVector(xRows, nil)
+	buf := make([]float64, xCols)
 	for i := 0; i < xRows; i++ {
-		label, _, err := km.near(i, &DenseVector{X.RowView(i).(*mat.VecDense)})
+		label, d, err := km.near(dist, i, X.RowTo(buf, i))
 		if err != nil {
 			return &DenseVector{&mat.VecDense{}}, fmt.Errorf("kmodes Predict: %v", err)
 		}
+		if km.NoveltyQuantile > 0 && d > noveltyThreshold(km.DistanceQuantiles, int(label), km.NoveltyQuantile) {
+			label = NoveltyLabel
+		}
 		labelsVec.SetVec(i, label)
 	}
 	return labelsVec, nil
 }
 
+// Transform computes distances of the new vectors to all cluster centroids,
+// row i of the result holds distances of vector i. Distances to dropped
+// clusters are +Inf. Margins and SoftAssignments derive border and membership
+// scores from the result. Like Predict, it may be called concurrently.
+func (km *KModes) Transform(X Matrix) (*DenseMatrix, error) {
+	if !km.IsFitted {
+		return nil, errors.New("kmodes: cannot transform vectors, model is not fitted yet")
+	}
+	dist := modelDistance(km.DistanceFunc, km.WeightVectors)
+	xRows, xCols := X.Dims()
+	distances := NewDenseMatrix(xRows, km.ClustersNumber, nil)
+	buf := make([]float64, xCols)
+	for i := 0; i < xRows; i++ {
+		row := X.RowTo(buf, i)
+		for c := 0; c < km.ClustersNumber; c++ {
+			if isDropped(km.DroppedClusters, c) {
+				distances.Set(i, c, math.Inf(1))
+				continue
+			}
+			d, err := dist(row, km.ClusterCentroids.RawRowView(c))
+			if err != nil {
+				return nil, fmt.Errorf("kmodes Transform: cannot compute distance of vector %d: %v", i, err)
+			}
+			distances.Set(i, c, d)
+		}
+	}
+	return distances, nil
+}
+
 // SaveModel saves computed ml model (KModes struct) in file specified in
 // configuration.
 func (km *KModes) SaveModel() error {
@@ -262,17 +808,18 @@ func (km *KModes) SaveModel() error {
 // LoadModel loads model (KModes struct) from file.
 func (km *KModes) LoadModel() error {
 	file, err := os.Open(km.ModelPath)
-	if err == nil {
-		decoder := gob.NewDecoder(file)
-		err = decoder.Decode(km)
+	if err != nil {
+		return err
 	}
-	file.Close()
-	SetWeights(km.WeightVectors[0])
-	return err
+	defer file.Close()
+	if err := gob.NewDecoder(file).Decode(km); err != nil {
+		return err
+	}
+	return nil
 }
 
 func (km *KModes) validateParameters() error {
-	if km.InitializationFunc == nil {
+	if km.InitializationFunc == nil && km.InitialCentroids == nil {
 		return errors.New("initializationFunction is nil")
 	}
 	if km.DistanceFunc == nil {
@@ -1,8 +1,12 @@
 package cluster
 
 import (
+	"math"
+	"math/rand"
+	"path/filepath"
 	"reflect"
 	"sort"
+	"sync"
 	"testing"
 
 	"gonum.org/v1/gonum/mat"
@@ -88,12 +92,49 @@ func TestKModes_FitModel(t *testing.T) {
 
 }
 
+func TestKModes_FitModelSeed(t *testing.T) {
+	X := NewDenseMatrix(12, 3, []float64{
+		1, 2, 3, 1, 2, 1, 2, 2, 3, 3, 1, 1,
+		1, 1, 2, 3, 3, 3, 2, 1, 3, 1, 2, 2,
+		3, 2, 1, 2, 2, 2, 1, 3, 3, 2, 1, 3,
+	})
+
+	fit := func(seed int64) *KModes {
+		km := NewKModes(HammingDistance, InitRandom, 3, 1, 10, [][]float64{{1, 1, 1}}, "")
+		km.Seed = seed
+		if err := km.FitModel(X); err != nil {
+			t.Fatalf("KModes.FitModel() error = %v", err)
+		}
+		return km
+	}
+
+	// Global random source must not be touched by the model.
+	rand.Seed(7)
+	want := rand.Int63()
+	rand.Seed(7)
+
+	for seed := int64(0); seed < 5; seed++ {
+		km1, km2 := fit(seed), fit(seed)
+		if !reflect.DeepEqual(km1.Labels, km2.Labels) {
+			t.Errorf("seed %d: KModes.Labels = %v and %v", seed, km1.Labels.RawVector().Data, km2.Labels.RawVector().Data)
+		}
+		if !reflect.DeepEqual(km1.ClusterCentroids, km2.ClusterCentroids) {
+			t.Errorf("seed %d: KModes.ClusterCentroids = %v and %v", seed, km1.ClusterCentroids.RawMatrix().Data, km2.ClusterCentroids.RawMatrix().Data)
+		}
+	}
+
+	if got := rand.Int63(); got != want {
+		t.Errorf("KModes.FitModel() used global random source")
+	}
+}
+
 func TestKModes_SaveModel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "km.txt")
 	tests := []struct {
 		km      *KModes
 		wantErr bool
 	}{
-		{km: &KModes{DistanceFunc: HammingDistance, InitializationFunc: InitCao, ClustersNumber: 2, RunsNumber: 1, MaxIterationNumber: 10, WeightVectors: [][]float64{{1, 1, 1}}, ModelPath: "km.txt"},
+		{km: &KModes{DistanceFunc: HammingDistance, InitializationFunc: InitCao, ClustersNumber: 2, RunsNumber: 1, MaxIterationNumber: 10, WeightVectors: [][]float64{{1, 1, 1}}, ModelPath: path},
 			wantErr: false},
 		{km: &KModes{DistanceFunc: HammingDistance, InitializationFunc: InitCao, ClustersNumber: 2, RunsNumber: 1, MaxIterationNumber: 10, WeightVectors: [][]float64{{1, 1, 1}}, ModelPath: ""},
 			wantErr: true},
@@ -108,12 +149,13 @@ func TestKModes_SaveModel(t *testing.T) {
 }
 
 func TestKModes_LoadModel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "km.txt")
 	tests := []struct {
 		name    string
 		km      *KModes
 		wantErr bool
 	}{
-		{km: &KModes{DistanceFunc: HammingDistance, InitializationFunc: InitCao, ClustersNumber: 2, RunsNumber: 1, MaxIterationNumber: 10, WeightVectors: [][]float64{{1, 1, 1}}, ModelPath: "km.txt"},
+		{km: &KModes{DistanceFunc: HammingDistance, InitializationFunc: InitCao, ClustersNumber: 2, RunsNumber: 1, MaxIterationNumber: 10, WeightVectors: [][]float64{{1, 1, 1}}, ModelPath: path},
 			wantErr: false},
 		{km: &KModes{DistanceFunc: HammingDistance, InitializationFunc: InitCao, ClustersNumber: 2, RunsNumber: 1, MaxIterationNumber: 10, WeightVectors: [][]float64{{1, 1, 1}}, ModelPath: ""},
 			wantErr: true},
@@ -184,27 +226,102 @@ func TestKModes_Predict(t *testing.T) {
 	}
 }
 
+func TestKModes_Transform(t *testing.T) {
+	X := randomCategorical(100, 5, 3, 2)
+	km := NewKModes(HammingDistance, InitCao, 4, 1, 20, [][]float64{{1}}, "")
+	km.Seed = 1
+	if _, err := km.Transform(X); err == nil {
+		t.Errorf("KModes.Transform() of not fitted model did not fail")
+	}
+	if err := km.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+	km.DroppedClusters = []int{3}
+
+	labels, err := km.Predict(X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	distances, err := km.Transform(X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, c := distances.Dims(); r != 100 || c != 4 {
+		t.Fatalf("KModes.Transform() dims = %d, %d", r, c)
+	}
+	for i := 0; i < 100; i++ {
+		row := distances.RawRowView(i)
+		if !math.IsInf(row[3], 1) {
+			t.Errorf("KModes.Transform() distance to dropped cluster = %v", row[3])
+		}
+		want, _ := RawHammingDistance(X.RawRowView(i), km.ClusterCentroids.RawRowView(0))
+		if row[0] != want {
+			t.Errorf("KModes.Transform() row %d = %v, want %v in the first column", i, row, want)
+		}
+		label := int(labels.At(i, 0))
+		for c, d := range row {
+			if d < row[label] {
+				t.Errorf("KModes.Transform() row %d = %v, cluster %d is nearer than %d", i, row, c, label)
+			}
+		}
+	}
+}
+
 func Test_findHighestMapValue(t *testing.T) {
 
 	tests := []struct {
-		m     map[float64]float64
-		want  float64
-		want1 bool
+		m        map[float64]float64
+		policy   TieBreak
+		previous float64
+		global   map[float64]float64
+		want     float64
+		want1    bool
 	}{
 		{m: map[float64]float64{1.0: 1.0, 2.0: 2.0}, want: 2.0, want1: false},
 		{m: map[float64]float64{1.0: 5.7, 2.0: 2.0, 3.0: 5.8}, want: 3.0, want1: false},
 		{m: map[float64]float64{}, want: 0, want1: true},
+		{m: map[float64]float64{1.0: 0, 2.0: 0}, want: 0, want1: true},
+		{m: map[float64]float64{3.0: 2, 2.0: 2, 4.0: 2, 1.0: 1}, policy: TieBreakSmallest, want: 2.0},
+		{m: map[float64]float64{3.0: 2, 2.0: 2, 4.0: 2, 1.0: 1}, policy: TieBreakPrevious, previous: 4.0, want: 4.0},
+		{m: map[float64]float64{3.0: 2, 2.0: 2, 4.0: 2, 1.0: 1}, policy: TieBreakPrevious, previous: 1.0, want: 2.0},
+		{m: map[float64]float64{3.0: 2, 2.0: 2, 4.0: 2, 1.0: 1}, policy: TieBreakGlobalFrequency, global: map[float64]float64{1.0: 9, 2.0: 3, 3.0: 5, 4.0: 5}, want: 3.0},
 	}
-	for _, tt := range tests {
-
-		got, got1 := findHighestMapValue(tt.m)
-		if got != tt.want {
-			t.Errorf("findHighestMapValue() got = %v, want %v", got, tt.want)
-		}
-		if got1 != tt.want1 {
-			t.Errorf("findHighestMapValue() got1 = %v, want %v", got1, tt.want1)
+	for i, tt := range tests {
+		for n := 0; n < 20; n++ {
+			got, got1 := findHighestMapValue(tt.m, tt.policy, tt.previous, tt.global)
+			if got != tt.want {
+				t.Errorf("%d. findHighestMapValue() got = %v, want %v", i, got, tt.want)
+			}
+			if got1 != tt.want1 {
+				t.Errorf("%d. findHighestMapValue() got1 = %v, want %v", i, got1, tt.want1)
+			}
 		}
+	}
+}
 
+func TestKModes_FitModelTieBreak(t *testing.T) {
+	// Every cluster has attributes with equally frequent values.
+	X := NewDenseMatrix(8, 2, []float64{
+		1, 1, 1, 2, 2, 1, 2, 2,
+		3, 3, 3, 4, 4, 3, 4, 4,
+	})
+
+	for _, policy := range []TieBreak{TieBreakSmallest, TieBreakPrevious, TieBreakGlobalFrequency} {
+		var first *KModes
+		for n := 0; n < 20; n++ {
+			km := NewKModes(HammingDistance, InitCao, 2, 1, 10, [][]float64{{1, 1}}, "")
+			km.TieBreakPolicy = policy
+			if err := km.FitModel(X); err != nil {
+				t.Fatalf("KModes.FitModel() error = %v", err)
+			}
+			if first == nil {
+				first = km
+				continue
+			}
+			if !reflect.DeepEqual(km.Labels, first.Labels) || !reflect.DeepEqual(km.ClusterCentroids, first.ClusterCentroids) {
+				t.Errorf("policy %d: KModes.FitModel() results differ between fits: %v and %v", policy, km.ClusterCentroids.RawMatrix().Data, first.ClusterCentroids.RawMatrix().Data)
+			}
+		}
 	}
 }
 
@@ -236,3 +353,115 @@ func sortMatrix(X *DenseMatrix) *DenseMatrix {
 	return NewDenseMatrix(xr, xc, flatten)
 
 }
+
+// randomCategorical generates rows x cols matrix with categorical values in
+// range [0, values).
+func TestFitModelConcurrentWeights(t *testing.T) {
+	// KPrototypes uses the last column as numerical.
+	X := randomCategorical(200, 5, 4, 5)
+	weightVectors := [][]float64{{1, 1, 1, 8, 1}, {8, 1, 1, 1, 1}}
+
+	// A custom initialization must see weights of its model too. Concurrent
+	// fits wait for each other in it, so that both have started before
+	// distances are computed.
+	customDistances := make([]float64, len(weightVectors))
+	custom := func(i int, barrier *sync.WaitGroup) InitializationFunction {
+		return func(X Matrix, clustersNumber int, distFunc DistanceFunction, rnd *rand.Rand) (*DenseMatrix, error) {
+			if barrier != nil {
+				barrier.Done()
+				barrier.Wait()
+			}
+			d, err := distFunc(NewDenseVector(5, []float64{0, 0, 0, 0, 0}), NewDenseVector(5, []float64{1, 0, 0, 0, 0}))
+			customDistances[i] = d
+			if err != nil {
+				return nil, err
+			}
+			return InitHuang(X, clustersNumber, distFunc, rnd)
+		}
+	}
+	fit := func(i int, init InitializationFunction) (*KModes, *KPrototypes, error) {
+		km := NewKModes(WeightedHammingDistance, init, 4, 1, 20, [][]float64{weightVectors[i]}, "")
+		km.Seed = 1
+		if err := km.FitModel(X); err != nil {
+			return nil, nil, err
+		}
+		kp := NewKPrototypes(WeightedHammingDistance, nil, []int{0, 1, 2, 3}, 4, 1, 20, [][]float64{weightVectors[i][:4]}, 1, "")
+		kp.MixedInitFunc = InitCaoMixed
+		kp.Seed = 1
+		if err := kp.FitModel(NewDenseMatrix(200, 5, append([]float64(nil), X.RawMatrix().Data...))); err != nil {
+			return nil, nil, err
+		}
+		return km, kp, nil
+	}
+
+	for _, init := range []InitializationFunction{InitCao, InitHuang, nil} {
+		want := make([]*KModes, len(weightVectors))
+		wantKP := make([]*KPrototypes, len(weightVectors))
+		for i := range weightVectors {
+			initFunc := init
+			if initFunc == nil {
+				initFunc = custom(i, nil)
+			}
+			var err error
+			if want[i], wantKP[i], err = fit(i, initFunc); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Models with different weights are fitted concurrently, run with
+		// -race to check that they share no weights.
+		got := make([]*KModes, len(weightVectors))
+		gotKP := make([]*KPrototypes, len(weightVectors))
+		errs := make([]error, len(weightVectors))
+		var barrier, wg sync.WaitGroup
+		barrier.Add(len(weightVectors))
+		for i := range weightVectors {
+			initFunc := init
+			if initFunc == nil {
+				initFunc = custom(i, &barrier)
+			}
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				got[i], gotKP[i], errs[i] = fit(i, initFunc)
+			}(i)
+		}
+		wg.Wait()
+		for i := range weightVectors {
+			if errs[i] != nil {
+				t.Fatal(errs[i])
+			}
+			if !reflect.DeepEqual(got[i].ClusterCentroids, want[i].ClusterCentroids) || !reflect.DeepEqual(got[i].Labels, want[i].Labels) {
+				t.Errorf("KModes.FitModel() with weights %v differs when fitted concurrently", weightVectors[i])
+			}
+			if !reflect.DeepEqual(gotKP[i].ClusterCentroids, wantKP[i].ClusterCentroids) || !reflect.DeepEqual(gotKP[i].Labels, wantKP[i].Labels) {
+				t.Errorf("KPrototypes.FitModel() with weights %v differs when fitted concurrently", weightVectors[i])
+			}
+		}
+	}
+	if want := []float64{1, 8}; !reflect.DeepEqual(customDistances, want) {
+		t.Errorf("distances in custom initialization = %v, want %v", customDistances, want)
+	}
+}
+
+func randomCategorical(rows, cols, values int, seed int64) *DenseMatrix {
+	rnd := rand.New(rand.NewSource(seed))
+	data := make([]float64, rows*cols)
+	for i := range data {
+		data[i] = float64(rnd.Intn(values))
+	}
+	return NewDenseMatrix(rows, cols, data)
+}
+
+func BenchmarkKModes_FitModel(b *testing.B) {
+	X := randomCategorical(5000, 20, 5, 1)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		km := NewKModes(HammingDistance, InitRandom, 20, 1, 5, [][]float64{{1}}, "")
+		km.Seed = 1
+		if err := km.FitModel(X); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -12,31 +12,65 @@ import (
 	"gonum.org/v1/gonum/mat"
 )
 
+// MixedInitializationFunction compute initial prototypes for mixed data, it
+// returns categorical and numerical parts of cluster centroids. Any randomness
+// must be drawn from rnd.
+type MixedInitializationFunction func(xCat, xNum *DenseMatrix, clustersNumber int, gamma float64, distFunc DistanceFunction, rnd *rand.Rand) (*DenseMatrix, *DenseMatrix, error)
+
 // KPrototypes is a basic class for the k-prototypes algorithm, it contains all
 // necessary information as alg. parameters, labels, centroids, ...
 type KPrototypes struct {
 	DistanceFunc        DistanceFunction
 	InitializationFunc  InitializationFunction
+	MixedInitFunc       MixedInitializationFunction // if set, used instead of InitializationFunc to pick whole records as prototypes
 	CategoricalInd      []int
 	ClustersNumber      int
 	RunsNumber          int
 	MaxIterationNumber  int
 	WeightVectors       [][]float64
 	FrequencyTable      [][]map[float64]float64 // frequency table - list of lists with dictionaries containing frequencies of values per cluster and attribute
-	MembershipNumTable  [][]float64             // membership table for numeric attributes - list of labels for each cluster
+	NumericSums         [][]float64             // sums of numeric attributes per cluster - updated when a row changes cluster
+	NumericScales       []float64               // maximum of every numeric attribute in training data, used to normalize data in Predict
 	LabelsCounter       []int
+	ClusterWeights      []float64 // sums of sample weights of cluster members, equal to LabelsCounter without weights
 	Labels              *DenseVector
-	ClusterCentroids    *DenseMatrix
+	ClusterCentroids    *DenseMatrix // both parts of centroids in the column order of the data, numerical attributes are normalized
 	ClusterCentroidsCat *DenseMatrix
 	ClusterCentroidsNum *DenseMatrix
 	Gamma               float64
 	IsFitted            bool
 	ModelPath           string
+	Seed                int64                // seed of the random source used by initialization and fitting
+	TieBreakPolicy      TieBreak             // how modes are chosen among equally frequent values
+	EmptyClusterAction  EmptyClusterStrategy // what to do with clusters which lost all members
+	DroppedClusters     []int                // clusters dropped during fitting with EmptyClusterDrop
+	CostTolerance       float64              // stop when relative cost improvement is below this value
+	MovedTolerance      float64              // stop when fraction of rows (by weight) changing cluster is below this value
+	TimeLimit           time.Duration        // stop when fitting takes longer, the model is fitted with StopReason StopTimeLimit
+	StopReason          StopReason           // rule which ended the last fit
+	Iterations          int                  // number of iterations done by the last fit
+	Cost                float64              // cost of the last iteration
+	DistanceQuantiles   [][]float64          // per cluster quantiles of members' distances to the centroid at levels 0, 0.01, ..., 1, recorded by FitModel
+	NoveltyQuantile     float64              // in (0, 1], if set, Predict labels rows farther from the centroid than this quantile of members' distances with NoveltyLabel; 0 disables it
+	Constraints         *Constraints         // if set, the assignment step honors must-link and cannot-link constraints of rows
+	Violations          []Violation          // constraints not satisfied by Labels after the last fit with Constraints
+	MinClusterSize      int                  // if set, every cluster keeps at least this number of rows
+	MaxClusterSize      int                  // if set, no cluster gets more than this number of rows
+	InitialCentroids    [][]float64          // if set, fitting starts from these centroids (columns of the data, numerical attributes in original units) instead of initialization functions
+	FixedClusters       []int                // clusters which keep their InitialCentroids during fitting
+
+	rnd             *rand.Rand
+	rawDist         RawDistanceFunction
+	globalFrequency []map[float64]float64
+	sampleWeights   []float64 // weights of rows of the fitted dataset, nil if rows are not weighted
+	constraints     *constraintSet
+	distBuf         []float64 // buffer for distances of a row to all clusters
+	balanced        []int     // clusters of rows chosen for the current pass when cluster sizes are limited
+	balancedDist    []float64 // distances of rows to balanced clusters
 }
 
 // NewKPrototypes implements constructor for the KPrototypes struct.
 func NewKPrototypes(dist DistanceFunction, init InitializationFunction, categorical []int, clusters int, runs int, iters int, weights [][]float64, g float64, modelPath string) *KPrototypes {
-	rand.Seed(time.Now().UnixNano())
 	return &KPrototypes{DistanceFunc: dist,
 		InitializationFunc:  init,
 		ClustersNumber:      clusters,
@@ -46,6 +80,7 @@ func NewKPrototypes(dist DistanceFunction, init InitializationFunction, categori
 		Gamma:               g,
 		WeightVectors:       weights,
 		ModelPath:           modelPath,
+		Seed:                time.Now().UnixNano(),
 		Labels:              &DenseVector{VecDense: new(mat.VecDense)},
 		ClusterCentroidsCat: &DenseMatrix{Dense: new(mat.Dense)},
 		ClusterCentroidsNum: &DenseMatrix{Dense: new(mat.Dense)},
@@ -55,13 +90,90 @@ func NewKPrototypes(dist DistanceFunction, init InitializationFunction, categori
 
 // FitModel main algorithm function which finds the best clusters centers for
 // the given dataset X.
+//
+// If Constraints is set, rows are assigned as in COP-k-means: every row joins
+// the nearest cluster which does not break constraints with rows assigned
+// before it in the same pass. When there is no such cluster, the row joins the
+// nearest one and the broken constraints are reported in Violations.
+//
+// If MinClusterSize or MaxClusterSize is set, every pass first finds the
+// nearest clusters of all rows, then rows are transferred greedily, cheapest
+// increase of distance first, out of too large clusters and into too small
+// ones. Limits apply to numbers of rows and cannot be combined with
+// Constraints.
+//
+// If InitialCentroids is set, e.g. to CentroidRows of a previous model, it is
+// used instead of initialization functions. Centroids of FixedClusters are not
+// updated, such clusters only gather rows and are never refilled or dropped
+// when empty.
 func (km *KPrototypes) FitModel(X *DenseMatrix) error {
+	return km.fit(X, nil)
+}
+
+// FitModelWeighted fits the model like FitModel, row i of X counts as
+// weights[i] rows. Frequency tables, numeric sums, ClusterWeights, cost and
+// distance quantiles are weighted, so integer weights give the same model as
+// repeating rows. Densities of InitCao and InitCaoMixed are weighted too, other
+// initializations ignore weights. Weights must be positive.
+func (km *KPrototypes) FitModelWeighted(X *DenseMatrix, weights []float64) error {
+	xRows, _ := X.Dims()
+	if err := checkSampleWeights(weights, xRows); err != nil {
+		return fmt.Errorf("kmodes: failed to fit the model: %v", err)
+	}
+	return km.fit(X, weights)
+}
+
+func (km *KPrototypes) fit(X *DenseMatrix, weights []float64) error {
+	start := time.Now()
 
 	err := km.validateParameters()
 	if err != nil {
 		return fmt.Errorf("kmodes: failed to fit the model: %v", err)
 	}
+	_, xCols := X.Dims()
+	if err := checkInitialCentroids(km.InitialCentroids, km.FixedClusters, km.ClustersNumber, xCols); err != nil {
+		return fmt.Errorf("kmodes: failed to fit the model: %v", err)
+	}
+	km.Violations = nil
+	if km.MinClusterSize > 0 || km.MaxClusterSize > 0 {
+		if km.Constraints != nil {
+			return errors.New("kmodes: failed to fit the model: cluster size limits cannot be used with constraints")
+		}
+		xRows, _ := X.Dims()
+		if err := checkClusterSizes(km.MinClusterSize, km.MaxClusterSize, km.ClustersNumber, xRows); err != nil {
+			return fmt.Errorf("kmodes: failed to fit the model: %v", err)
+		}
+		defer func() {
+			km.balanced, km.balancedDist = nil, nil
+		}()
+	}
+	if km.Constraints != nil {
+		xRows, _ := X.Dims()
+		km.constraints, err = newConstraintSet(km.Constraints, xRows)
+		if err != nil {
+			return fmt.Errorf("kmodes: failed to fit the model: %v", err)
+		}
+		km.distBuf = make([]float64, km.ClustersNumber)
+		defer func() {
+			km.constraints, km.distBuf = nil, nil
+		}()
+	}
+	if err := km.fitRows(X, weights, start); err != nil {
+		return err
+	}
+	km.Violations = km.Constraints.violations(km.Labels)
+	return nil
+}
+
+// fitRows runs the whole algorithm on rows of X.
+func (km *KPrototypes) fitRows(X *DenseMatrix, weights []float64, start time.Time) error {
+	var err error
+	km.sampleWeights = weights
+	defer func() {
+		km.sampleWeights = nil
+	}()
 	xRows, xCols := X.Dims()
+	totalWeight := totalSampleWeight(weights, xRows)
 
 	// Partition data on two sets - one with categorical, other with numerical
 	// data.
@@ -71,26 +183,49 @@ func (km *KPrototypes) FitModel(X *DenseMatrix) error {
 	_, xNumCols := xNum.Dims()
 
 	// Normalize numerical values.
-	xNum = normalizeNum(xNum)
+	km.NumericScales = normalizeNum(xNum, nil)
 
-	// Initialize weightVector.
-	SetWeights(km.WeightVectors[0])
+	km.rawDist = modelDistance(km.DistanceFunc, km.WeightVectors)
 
-	// Initialize clusters for categorical data.
-	km.ClusterCentroidsCat, err = km.InitializationFunc(xCat, km.ClustersNumber, km.DistanceFunc)
-	if err != nil {
-		return fmt.Errorf("kmodes: failed to fit the model: %v", err)
+	// Initialize random source, global one is never used in order to make
+	// results reproducible.
+	km.rnd = rand.New(rand.NewSource(km.Seed))
+
+	if km.InitialCentroids != nil {
+		// Start from given centroids, normalized as the data.
+		km.ClusterCentroidsCat, km.ClusterCentroidsNum = km.partitionData(km.ClustersNumber, xCols, centroidMatrix(km.InitialCentroids))
+		normalizeNum(km.ClusterCentroidsNum, km.NumericScales)
+	} else if km.MixedInitFunc != nil {
+		// Initialize clusters for both categorical and numerical data.
+		km.ClusterCentroidsCat, km.ClusterCentroidsNum, err = modelMixedInitialization(km.MixedInitFunc, km.rawDist, weights)(xCat, xNum, km.ClustersNumber, km.Gamma, boundDistance(km.DistanceFunc, km.WeightVectors), km.rnd)
+		if err != nil {
+			return fmt.Errorf("kmodes: failed to fit the model: %v", err)
+		}
+	} else {
+		// Initialize clusters for categorical data.
+		km.ClusterCentroidsCat, err = modelInitialization(km.InitializationFunc, km.rawDist, weights)(xCat, km.ClustersNumber, boundDistance(km.DistanceFunc, km.WeightVectors), km.rnd)
+		if err != nil {
+			return fmt.Errorf("kmodes: failed to fit the model: %v", err)
+		}
+
+		// Initialize clusters for numerical data.
+		km.ClusterCentroidsNum, err = InitNum(xNum, km.ClustersNumber, km.DistanceFunc, km.rnd)
+		if err != nil {
+			return fmt.Errorf("kmodes: failed to initialiaze cluster centers for numerical data: %v", err)
+		}
 	}
 
-	// Initialize clusters for numerical data.
-	km.ClusterCentroidsNum, err = InitNum(xNum, km.ClustersNumber, km.DistanceFunc)
-	if err != nil {
-		return fmt.Errorf("kmodes: failed to initialiaze cluster centers for numerical data: %v", err)
+	if km.TieBreakPolicy == TieBreakGlobalFrequency {
+		km.globalFrequency = columnFrequencies(xCat, weights)
 	}
 
 	// Initialize labels vector
 	km.Labels = NewDenseVector(xRows, nil)
 	km.LabelsCounter = make([]int, km.ClustersNumber)
+	km.ClusterWeights = make([]float64, km.ClustersNumber)
+	km.DroppedClusters = nil
+	km.StopReason = StopNone
+	km.IsFitted = false
 
 	// Create frequency table for categorical data.
 	km.FrequencyTable = make([][]map[float64]float64, km.ClustersNumber)
@@ -101,48 +236,89 @@ func (km *KPrototypes) FitModel(X *DenseMatrix) error {
 		}
 	}
 
-	// Create membership table.
-	km.MembershipNumTable = make([][]float64, km.ClustersNumber)
-	for i := range km.MembershipNumTable {
-		km.MembershipNumTable[i] = make([]float64, 0, 100)
+	// Create table of numeric sums.
+	km.NumericSums = make([][]float64, km.ClustersNumber)
+	for i := range km.NumericSums {
+		km.NumericSums[i] = make([]float64, xNumCols)
 	}
 
 	// Perform initial assignements to clusters - in order to fill in frequency
 	// table.
+	costs := make([]float64, xRows)
+	if err := km.balance(xCat, xNum); err != nil {
+		return fmt.Errorf("kmodes: initial labels assignement failure: %v", err)
+	}
 	for i := 0; i < xRows; i++ {
-		rowCat := &DenseVector{xCat.RowView(i).(*mat.VecDense)}
-		rowNum := &DenseVector{xNum.RowView(i).(*mat.VecDense)}
-		newLabel, _, err := km.near(i, rowCat, rowNum)
+		rowCat := xCat.RawRowView(i)
+		newLabel, cost, err := km.assign(i, rowCat, xNum.RawRowView(i))
+		w := sampleWeight(weights, i)
+		costs[i] = w * cost
 		km.Labels.SetVec(i, newLabel)
 		km.LabelsCounter[int(newLabel)]++
+		km.ClusterWeights[int(newLabel)] += w
 		if err != nil {
 			return fmt.Errorf("kmodes: initial labels assignement failure: %v", err)
 		}
 		for j := 0; j < xCatCols; j++ {
-			km.FrequencyTable[int(newLabel)][j][rowCat.At(j, 0)]++
+			km.FrequencyTable[int(newLabel)][j][rowCat[j]] += w
+		}
+		for j, v := range xNum.RawRowView(i) {
+			km.NumericSums[int(newLabel)][j] += w * v
 		}
-		km.MembershipNumTable[int(newLabel)] = append(km.MembershipNumTable[int(newLabel)], float64(i))
 
 	}
 
+	// Initial centroids may leave some clusters empty.
+	km.handleEmptyClusters(xNum, xCat, costs, make([]bool, km.ClustersNumber))
+	var prevCost float64
+	for _, cost := range costs {
+		prevCost += cost
+	}
+
 	// Perform initial centers update - because iteration() starts with label
 	// assignements.
 	for i := 0; i < km.ClustersNumber; i++ {
 		// Find new values for clusters centers.
-		km.findNewCenters(xCatCols, xNumCols, i, xNum)
+		km.findNewCenters(xCatCols, xNumCols, i)
 
 	}
 	for i := 0; i < km.MaxIterationNumber; i++ {
-		_, change, err := km.iteration(xNum, xCat)
+		cost, moved, err := km.iteration(xNum, xCat)
 		if err != nil {
 			return fmt.Errorf("KMeans error at iteration %d: %v", i, err)
 		}
-		if !change {
+		km.Iterations = i + 1
+		km.Cost = cost
+		if reason, stop := stopRule(prevCost, cost, moved, totalWeight, km.CostTolerance, km.MovedTolerance, start, km.TimeLimit); stop {
+			km.StopReason = reason
 			km.IsFitted = true
-			return nil
+			km.joinCentroids()
+			return km.recordDistanceQuantiles(xCat, xNum)
 		}
+		prevCost = cost
 	}
+	// Centroids are usable when the iteration budget runs out too,
+	// StopReason tells whether they are stable.
+	km.StopReason = StopMaxIterations
+	km.IsFitted = true
+	km.joinCentroids()
 
+	return km.recordDistanceQuantiles(xCat, xNum)
+}
+
+// recordDistanceQuantiles stores quantiles of distances of rows to their
+// cluster centroids in DistanceQuantiles, weighted by sample weights.
+func (km *KPrototypes) recordDistanceQuantiles(xCat, xNum *DenseMatrix) error {
+	xRows, _ := xCat.Dims()
+	distances := make([]float64, xRows)
+	for i := 0; i < xRows; i++ {
+		d, err := km.distance(km.rawDist, int(km.Labels.At(i, 0)), xCat.RawRowView(i), xNum.RawRowView(i))
+		if err != nil {
+			return fmt.Errorf("kmodes: cannot compute distance quantiles: %v", err)
+		}
+		distances[i] = d
+	}
+	km.DistanceQuantiles = distanceQuantiles(km.Labels, distances, km.sampleWeights, km.ClustersNumber)
 	return nil
 }
 
@@ -169,68 +345,87 @@ func (km *KPrototypes) partitionData(xRows, xCols int, X *DenseMatrix) (*DenseMa
 	return xCat, xNum
 }
 
-func (km *KPrototypes) iteration(xNum, xCat *DenseMatrix) (float64, bool, error) {
+// joinCentroids puts categorical and numerical parts of centroids together
+// in ClusterCentroids.
+func (km *KPrototypes) joinCentroids() {
+	_, xCatCols := km.ClusterCentroidsCat.Dims()
+	_, xNumCols := km.ClusterCentroidsNum.Dims()
+	km.ClusterCentroids = NewDenseMatrix(km.ClustersNumber, xCatCols+xNumCols, nil)
+	var lastCat, lastNum int
+	for j := 0; j < xCatCols+xNumCols; j++ {
+		for i := 0; i < km.ClustersNumber; i++ {
+			if lastCat < xCatCols && km.CategoricalInd[lastCat] == j {
+				km.ClusterCentroids.Set(i, j, km.ClusterCentroidsCat.At(i, lastCat))
+			} else {
+				km.ClusterCentroids.Set(i, j, km.ClusterCentroidsNum.At(i, lastNum))
+			}
+		}
+		if lastCat < xCatCols && km.CategoricalInd[lastCat] == j {
+			lastCat++
+		} else {
+			lastNum++
+		}
+	}
+}
+
+func (km *KPrototypes) iteration(xNum, xCat *DenseMatrix) (float64, float64, error) {
 	changed := make([]bool, km.ClustersNumber)
-	var change bool
 	var numOfChanges float64
 	var totalCost float64
 
-	for i := 0; i < km.ClustersNumber; i++ {
-		km.MembershipNumTable[i] = nil
-	}
-
 	// Find closest cluster for all data vectors - assign new labels.
 	xRowsNum, xNumCols := xNum.Dims()
 	_, xColsCat := xCat.Dims()
+	costs := make([]float64, xRowsNum)
+	if err := km.balance(xCat, xNum); err != nil {
+		return totalCost, numOfChanges, fmt.Errorf("iteration error: %v", err)
+	}
 
 	for i := 0; i < xRowsNum; i++ {
-		rowCat := &DenseVector{xCat.RowView(i).(*mat.VecDense)}
-		rowNum := &DenseVector{xNum.RowView(i).(*mat.VecDense)}
-		newLabel, cost, err := km.near(i, rowCat, rowNum)
+		newLabel, cost, err := km.assign(i, xCat.RawRowView(i), xNum.RawRowView(i))
 		if err != nil {
-			return totalCost, change, fmt.Errorf("iteration error: %v", err)
+			return totalCost, numOfChanges, fmt.Errorf("iteration error: %v", err)
 		}
-		totalCost += cost
-
-		km.MembershipNumTable[int(newLabel)] = append(km.MembershipNumTable[int(newLabel)], float64(i))
+		costs[i] = sampleWeight(km.sampleWeights, i) * cost
 
 		if newLabel != km.Labels.At(i, 0) {
-
-			km.LabelsCounter[int(newLabel)]++
-			km.LabelsCounter[int(km.Labels.At(i, 0))]--
-
-			// Make changes in frequency table.
-			for j := 0; j < xColsCat; j++ {
-				km.FrequencyTable[int(km.Labels.At(i, 0))][j][rowCat.At(j, 0)]--
-				km.FrequencyTable[int(newLabel)][j][rowCat.At(j, 0)]++
-
-			}
-			change = true
-
-			numOfChanges++
+			numOfChanges += sampleWeight(km.sampleWeights, i)
 			changed[int(newLabel)] = true
 			changed[int(km.Labels.At(i, 0))] = true
-			km.Labels.SetVec(i, newLabel)
+			km.moveRow(xNum, xCat, i, int(newLabel))
 		}
 
 	}
 
+	// Check for empty clusters.
+	numOfChanges += km.handleEmptyClusters(xNum, xCat, costs, changed)
+	for _, cost := range costs {
+		totalCost += cost
+	}
+
 	// Recompute cluster centers for all clusters with changes.
 	for i, elem := range changed {
 		if elem {
 			// Find new values for clusters centers.
-			km.findNewCenters(xColsCat, xNumCols, i, xNum)
+			km.findNewCenters(xColsCat, xNumCols, i)
 
 		}
 	}
 
-	return totalCost, change, nil
+	return totalCost, numOfChanges, nil
 }
 
-func (km *KPrototypes) findNewCenters(xColsCat, xNumCols, i int, xNum *DenseMatrix) {
+func (km *KPrototypes) findNewCenters(xColsCat, xNumCols, i int) {
+	if isFixed(km.FixedClusters, i) {
+		return
+	}
 	newCentroid := make([]float64, xColsCat)
 	for j := 0; j < xColsCat; j++ {
-		val, empty := findHighestMapValue(km.FrequencyTable[i][j])
+		var global map[float64]float64
+		if km.globalFrequency != nil {
+			global = km.globalFrequency[j]
+		}
+		val, empty := findHighestMapValue(km.FrequencyTable[i][j], km.TieBreakPolicy, km.ClusterCentroidsCat.At(i, j), global)
 		if !empty {
 			newCentroid[j] = val
 		} else {
@@ -239,86 +434,187 @@ func (km *KPrototypes) findNewCenters(xColsCat, xNumCols, i int, xNum *DenseMatr
 	}
 	km.ClusterCentroidsCat.SetRow(i, newCentroid)
 
-	vecSum := make([]*mat.VecDense, km.ClustersNumber)
-	for a := 0; a < km.ClustersNumber; a++ {
-		vecSum[a] = mat.NewVecDense(xNumCols, nil)
+	// Empty cluster keeps its previous center.
+	if km.LabelsCounter[i] == 0 {
+		return
 	}
+	newCenter := make([]float64, xNumCols)
+	for j := 0; j < xNumCols; j++ {
+		newCenter[j] = km.NumericSums[i][j] / km.ClusterWeights[i]
+	}
+	km.ClusterCentroidsNum.SetRow(i, newCenter)
+}
 
-	for a := 0; a < km.ClustersNumber; a++ {
-		newCenter := make([]float64, xNumCols)
-		for j := 0; j < km.LabelsCounter[a]; j++ {
-			for k := 0; k < xNumCols; k++ {
-				vecSum[a].SetVec(k, vecSum[a].At(k, 0)+xNum.At(int(km.MembershipNumTable[a][j]), k))
-			}
+// moveRow reassigns row i to the cluster newLabel, counters, frequency table
+// and numeric sums are updated accordingly.
+func (km *KPrototypes) moveRow(xNum, xCat *DenseMatrix, i, newLabel int) {
+	oldLabel := int(km.Labels.At(i, 0))
+	w := sampleWeight(km.sampleWeights, i)
+	km.LabelsCounter[newLabel]++
+	km.LabelsCounter[oldLabel]--
+	km.ClusterWeights[newLabel] += w
+	km.ClusterWeights[oldLabel] -= w
+
+	// Make changes in frequency table.
+	for j, v := range xCat.RawRowView(i) {
+		km.FrequencyTable[oldLabel][j][v] -= w
+		km.FrequencyTable[newLabel][j][v] += w
+	}
+	for j, v := range xNum.RawRowView(i) {
+		km.NumericSums[oldLabel][j] -= w * v
+		km.NumericSums[newLabel][j] += w * v
+	}
+	km.Labels.SetVec(i, float64(newLabel))
+}
 
+// handleEmptyClusters applies EmptyClusterAction to all clusters without
+// members. costs of moved rows are updated and clusters which need centers
+// recomputation are marked in changed. It returns the weight of moved rows.
+func (km *KPrototypes) handleEmptyClusters(xNum, xCat *DenseMatrix, costs []float64, changed []bool) float64 {
+	var moved float64
+	for i := 0; i < km.ClustersNumber; i++ {
+		if km.LabelsCounter[i] != 0 || isDropped(km.DroppedClusters, i) || isFixed(km.FixedClusters, i) {
+			continue
 		}
-		for l := 0; l < xNumCols; l++ {
-			newCenter[l] = vecSum[a].At(l, 0) / float64(km.LabelsCounter[a])
+		if km.EmptyClusterAction == EmptyClusterDrop {
+			km.DroppedClusters = append(km.DroppedClusters, i)
+			continue
 		}
-
-		km.ClusterCentroidsNum.SetRow(a, newCenter)
+		index, ok := emptyClusterRow(km.EmptyClusterAction, km.rnd, km.Labels, km.LabelsCounter, costs)
+		if !ok {
+			continue
+		}
+		changed[int(km.Labels.At(index, 0))] = true
+		changed[i] = true
+		km.moveRow(xNum, xCat, index, i)
+		km.ClusterCentroidsCat.SetRow(i, xCat.RawRowView(index))
+		km.ClusterCentroidsNum.SetRow(i, xNum.RawRowView(index))
+		costs[index] = 0
+		moved += sampleWeight(km.sampleWeights, index)
 	}
+	return moved
 }
 
-func (km *KPrototypes) near(index int, vectorCat, vectorNum *DenseVector) (float64, float64, error) {
+// near finds the nearest cluster for the vector with the distance dist used
+// for categorical attributes.
+func (km *KPrototypes) near(dist RawDistanceFunction, index int, vectorCat, vectorNum []float64) (float64, float64, error) {
 	var newLabel, distance float64
 	distance = math.MaxFloat64
 
 	for i := 0; i < km.ClustersNumber; i++ {
-		distCat, err := km.DistanceFunc(vectorCat, &DenseVector{km.ClusterCentroidsCat.RowView(i).(*mat.VecDense)})
-		if err != nil {
-			return -1, -1, fmt.Errorf("cannot compute nearest cluster for vector %q: %v", index, err)
+		if isDropped(km.DroppedClusters, i) {
+			continue
 		}
-		distNum, err := EuclideanDistance(vectorNum, &DenseVector{km.ClusterCentroidsNum.RowView(i).(*mat.VecDense)})
+		d, err := km.distance(dist, i, vectorCat, vectorNum)
 		if err != nil {
 			return -1, -1, fmt.Errorf("cannot compute nearest cluster for vector %q: %v", index, err)
 		}
-		dist := km.Gamma*distCat + distNum
-		if dist < distance {
-			distance = dist
+		if d < distance {
+			distance = d
 			newLabel = float64(i)
 		}
 	}
 	return newLabel, distance, nil
 }
 
-// Predict assign labels for the set of new vectors.
-func (km *KPrototypes) Predict(X *DenseMatrix) (*DenseVector, error) {
-	if !km.IsFitted {
-		return &DenseVector{&mat.VecDense{}}, errors.New("kmodes: cannot predict labels, model is not fitted yet")
+// assign finds the cluster for row i of the fitted dataset, constraints are
+// honored if set.
+func (km *KPrototypes) assign(i int, rowCat, rowNum []float64) (float64, float64, error) {
+	if km.balanced != nil {
+		return float64(km.balanced[i]), km.balancedDist[i], nil
 	}
-	xRows, xCols := X.Dims()
-	labelsVec := NewDenseVector(xRows, nil)
+	if km.constraints == nil {
+		return km.near(km.rawDist, i, rowCat, rowNum)
+	}
+	if err := km.clusterDistances(i, rowCat, rowNum, km.distBuf); err != nil {
+		return -1, -1, err
+	}
+	label, d := km.constraints.choose(i, km.distBuf, km.Labels)
+	return float64(label), d, nil
+}
 
-	// Split data on categorical and numerical.
-	xCat := NewDenseMatrix(xRows, len(km.CategoricalInd), nil)
-	xNum := NewDenseMatrix(xRows, xCols-len(km.CategoricalInd), nil)
-	var lastCat, lastNum int
-	for i := 0; i < xCols; i++ {
-		vec := make([]float64, xRows)
-		vec = mat.Col(vec, i, X)
+// balance chooses clusters of all rows for the next pass if cluster sizes
+// are limited.
+func (km *KPrototypes) balance(xCat, xNum *DenseMatrix) error {
+	if km.MinClusterSize == 0 && km.MaxClusterSize == 0 {
+		return nil
+	}
+	xRows, _ := xCat.Dims()
+	k := km.ClustersNumber
+	distances := make([]float64, xRows*k)
+	km.balanced = make([]int, xRows)
+	km.balancedDist = make([]float64, xRows)
+	for i := 0; i < xRows; i++ {
+		row := distances[i*k : (i+1)*k]
+		if err := km.clusterDistances(i, xCat.RawRowView(i), xNum.RawRowView(i), row); err != nil {
+			return err
+		}
+		km.balanced[i] = nearestCluster(row)
+	}
+	balanceLabels(km.balanced, distances, k, km.DroppedClusters, km.MinClusterSize, km.MaxClusterSize)
+	for i, c := range km.balanced {
+		km.balancedDist[i] = distances[i*k+c]
+	}
+	return nil
+}
 
-		if km.CategoricalInd[lastCat] == i {
-			xCat.SetCol(lastCat, vec)
-			lastCat++
-			if lastCat >= len(km.CategoricalInd) {
-				lastCat--
-			}
-		} else {
-			xNum.SetCol(lastNum, vec)
-			lastNum++
+// clusterDistances computes distances of row i to all clusters, +Inf for
+// dropped ones.
+func (km *KPrototypes) clusterDistances(i int, rowCat, rowNum []float64, distances []float64) error {
+	for c := range distances {
+		if isDropped(km.DroppedClusters, c) {
+			distances[c] = math.Inf(1)
+			continue
+		}
+		d, err := km.distance(km.rawDist, c, rowCat, rowNum)
+		if err != nil {
+			return fmt.Errorf("cannot compute nearest cluster for vector %q: %v", i, err)
 		}
+		distances[c] = d
 	}
+	return nil
+}
 
-	// Normalize numerical values.
-	xNum = normalizeNum(xNum)
+// distance computes distance of the vector to the centroid of cluster i.
+func (km *KPrototypes) distance(dist RawDistanceFunction, i int, vectorCat, vectorNum []float64) (float64, error) {
+	distCat, err := dist(vectorCat, km.ClusterCentroidsCat.RawRowView(i))
+	if err != nil {
+		return -1, err
+	}
+	distNum, err := RawEuclideanDistance(vectorNum, km.ClusterCentroidsNum.RawRowView(i))
+	if err != nil {
+		return -1, err
+	}
+	return km.Gamma*distCat + distNum, nil
+}
 
+// Predict assign labels for the set of new vectors. If NoveltyQuantile is
+// set (to a quantile in (0, 1], 0 means unset), vectors farther from the
+// nearest centroid than this quantile of distances of cluster members get
+// NoveltyLabel. It does not modify the model, so it may be called
+// concurrently.
+func (km *KPrototypes) Predict(X *DenseMatrix) (*DenseVector, error) {
+	if !km.IsFitted {
+		return &DenseVector{&mat.VecDense{}}, errors.New("kmodes: cannot predict labels, model is not fitted yet")
+	}
+	if err := checkNoveltyQuantile(km.NoveltyQuantile, km.DistanceQuantiles); err != nil {
+		return &DenseVector{&mat.VecDense{}}, fmt.Errorf("kmodes Predict: %v", err)
+	}
+	xRows, _ := X.Dims()
+	labelsVec := NewDenseVector(xRows, nil)
+	xCat, xNum, err := km.prepare(X)
+	if err != nil {
+		return &DenseVector{&mat.VecDense{}}, fmt.Errorf("kmodes Predict: %v", err)
+	}
+
+	dist := modelDistance(km.DistanceFunc, km.WeightVectors)
 	for i := 0; i < xRows; i++ {
-		catVector := &DenseVector{xCat.RowView(i).(*mat.VecDense)}
-		numVector := &DenseVector{xNum.RowView(i).(*mat.VecDense)}
-		label, _, err := km.near(i, catVector, numVector)
+		label, d, err := km.near(dist, i, xCat.RawRowView(i), xNum.RawRowView(i))
 		if err != nil {
-			return NewDenseVector(0, nil), fmt.Errorf("kmodes Predict: %v", err)
+			return &DenseVector{&mat.VecDense{}}, fmt.Errorf("kmodes Predict: %v", err)
+		}
+		if km.NoveltyQuantile > 0 && d > noveltyThreshold(km.DistanceQuantiles, int(label), km.NoveltyQuantile) {
+			label = NoveltyLabel
 		}
 		labelsVec.SetVec(i, label)
 	}
@@ -326,6 +622,53 @@ func (km *KPrototypes) Predict(X *DenseMatrix) (*DenseVector, error) {
 	return labelsVec, nil
 }
 
+// Transform computes distances of the new vectors to all cluster centroids,
+// row i of the result holds distances of vector i. Distances to dropped
+// clusters are +Inf. Margins and SoftAssignments derive border and membership
+// scores from the result. Like Predict, it may be called concurrently.
+func (km *KPrototypes) Transform(X *DenseMatrix) (*DenseMatrix, error) {
+	if !km.IsFitted {
+		return nil, errors.New("kmodes: cannot transform vectors, model is not fitted yet")
+	}
+	xRows, _ := X.Dims()
+	distances := NewDenseMatrix(xRows, km.ClustersNumber, nil)
+	xCat, xNum, err := km.prepare(X)
+	if err != nil {
+		return nil, fmt.Errorf("kmodes Transform: %v", err)
+	}
+
+	dist := modelDistance(km.DistanceFunc, km.WeightVectors)
+	for i := 0; i < xRows; i++ {
+		for c := 0; c < km.ClustersNumber; c++ {
+			if isDropped(km.DroppedClusters, c) {
+				distances.Set(i, c, math.Inf(1))
+				continue
+			}
+			d, err := km.distance(dist, c, xCat.RawRowView(i), xNum.RawRowView(i))
+			if err != nil {
+				return nil, fmt.Errorf("kmodes Transform: cannot compute distance of vector %d: %v", i, err)
+			}
+			distances.Set(i, c, d)
+		}
+	}
+	return distances, nil
+}
+
+// prepare splits new vectors on categorical and numerical parts and
+// normalizes the numerical one.
+func (km *KPrototypes) prepare(X *DenseMatrix) (*DenseMatrix, *DenseMatrix, error) {
+	xRows, xCols := X.Dims()
+	if km.NumericScales != nil && len(km.NumericScales) != xCols-len(km.CategoricalInd) {
+		return nil, nil, fmt.Errorf("model has %d numeric scales, vectors have %d numeric attributes", len(km.NumericScales), xCols-len(km.CategoricalInd))
+	}
+	xCat, xNum := km.partitionData(xRows, xCols, X)
+
+	// Normalize numerical values with the training scales, models saved
+	// before they were recorded use the maximum of the batch.
+	normalizeNum(xNum, km.NumericScales)
+	return xCat, xNum, nil
+}
+
 // SaveModel saves computed ml model (KPrototypes struct) in file specified in
 // configuration.
 func (km *KPrototypes) SaveModel() error {
@@ -341,29 +684,37 @@ func (km *KPrototypes) SaveModel() error {
 // LoadModel loads model (KPrototypes struct) from file.
 func (km *KPrototypes) LoadModel() error {
 	file, err := os.Open(km.ModelPath)
-	if err == nil {
-		decoder := gob.NewDecoder(file)
-		err = decoder.Decode(&km)
+	if err != nil {
+		return err
 	}
-	file.Close()
-	SetWeights(km.WeightVectors[0])
-	return err
+	defer file.Close()
+	if err := gob.NewDecoder(file).Decode(&km); err != nil {
+		return err
+	}
+	return nil
 }
 
-func normalizeNum(X *DenseMatrix) *DenseMatrix {
+// normalizeNum divides every column of X by its scale. If scales is nil,
+// maximum of the column is used. It returns the scales.
+func normalizeNum(X *DenseMatrix, scales []float64) []float64 {
 	xRows, xCols := X.Dims()
+	if scales == nil {
+		scales = make([]float64, xCols)
+		for i := range scales {
+			column := make([]float64, xRows)
+			scales[i] = maxVal(mat.Col(column, i, X))
+		}
+	}
 	for i := 0; i < xCols; i++ {
-		column := X.ColView(i).(*mat.VecDense).RawVector().Data
-		max := maxVal(column)
 		for j := 0; j < xRows; j++ {
-			X.Set(j, i, X.At(j, i)/max)
+			X.Set(j, i, X.At(j, i)/scales[i])
 		}
 	}
-	return X
+	return scales
 }
 
 func (km *KPrototypes) validateParameters() error {
-	if km.InitializationFunc == nil {
+	if km.InitializationFunc == nil && km.MixedInitFunc == nil && km.InitialCentroids == nil {
 		return errors.New("initializationFunction is nil")
 	}
 	if km.DistanceFunc == nil {
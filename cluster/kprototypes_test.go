@@ -1,6 +1,9 @@
 package cluster
 
 import (
+	"math"
+	"math/rand"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -32,6 +35,16 @@ func TestKPrototypes_FitModel(t *testing.T) {
 			CentersCat: cc1,
 			CentersNum: cn1,
 		},
+		{km: &KPrototypes{DistanceFunc: HammingDistance, MixedInitFunc: InitCaoMixed, CategoricalInd: []int{1}, Gamma: 1, ClustersNumber: 2, RunsNumber: 1, MaxIterationNumber: 10, WeightVectors: [][]float64{{1, 1, 1}}, ModelPath: "km.txt"},
+			X:          m1,
+			wantErr:    false,
+			CentersCat: cc1,
+			CentersNum: cn1,
+		},
+		{km: &KPrototypes{DistanceFunc: HammingDistance, CategoricalInd: []int{1}, Gamma: 1, ClustersNumber: 2, RunsNumber: 1, MaxIterationNumber: 10, WeightVectors: [][]float64{{1, 1, 1}}, ModelPath: "km.txt"},
+			X:       m1,
+			wantErr: true,
+		},
 	}
 	for i, tt := range tests {
 
@@ -56,6 +69,59 @@ func TestKPrototypes_FitModel(t *testing.T) {
 	}
 }
 
+func TestKPrototypes_FitModelSeed(t *testing.T) {
+	X := NewDenseMatrix(10, 2, []float64{
+		1, 1, 2, 1, 3, 2, 4, 2, 5, 1,
+		6, 2, 7, 3, 8, 3, 9, 1, 10, 3,
+	})
+
+	fit := func(seed int64, mixed bool) *KPrototypes {
+		kp := NewKPrototypes(HammingDistance, InitRandom, []int{1}, 3, 1, 10, [][]float64{{1}}, 0.5, "")
+		if mixed {
+			kp.MixedInitFunc = InitPlusPlusMixed
+		}
+		kp.Seed = seed
+		if err := kp.FitModel(X); err != nil {
+			t.Fatalf("KPrototypes.FitModel() error = %v", err)
+		}
+		return kp
+	}
+
+	// Global random source must not be touched by the model.
+	rand.Seed(7)
+	want := rand.Int63()
+	rand.Seed(7)
+
+	for seed := int64(0); seed < 5; seed++ {
+		for _, mixed := range []bool{false, true} {
+			kp1, kp2 := fit(seed, mixed), fit(seed, mixed)
+			if !reflect.DeepEqual(kp1.Labels, kp2.Labels) {
+				t.Errorf("seed %d: KPrototypes.Labels = %v and %v", seed, kp1.Labels.RawVector().Data, kp2.Labels.RawVector().Data)
+			}
+			if !sameBits(kp1.ClusterCentroidsCat.RawMatrix().Data, kp2.ClusterCentroidsCat.RawMatrix().Data) || !sameBits(kp1.ClusterCentroidsNum.RawMatrix().Data, kp2.ClusterCentroidsNum.RawMatrix().Data) {
+				t.Errorf("seed %d: KPrototypes centroids differ", seed)
+			}
+		}
+	}
+
+	if got := rand.Int63(); got != want {
+		t.Errorf("KPrototypes.FitModel() used global random source")
+	}
+}
+
+// sameBits reports whether two slices are bit-identical (NaN values included).
+func sameBits(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Float64bits(a[i]) != math.Float64bits(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
 func TestKPrototypes_Predict(t *testing.T) {
 	initMatrixKModes()
 	initCentersKModes()
@@ -98,3 +164,140 @@ func TestKPrototypes_Predict(t *testing.T) {
 		}
 	}
 }
+
+func TestKPrototypes_PredictScales(t *testing.T) {
+	X := NewDenseMatrix(6, 2, []float64{
+		1, 10,
+		1, 20,
+		1, 30,
+		2, 70,
+		2, 80,
+		2, 100,
+	})
+	kp := NewKPrototypes(HammingDistance, InitCao, []int{0}, 2, 1, 10, [][]float64{{1}}, 1, "")
+	kp.Seed = 1
+	if err := kp.FitModel(NewDenseMatrix(6, 2, append([]float64(nil), X.RawMatrix().Data...))); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(kp.NumericScales, []float64{100}) {
+		t.Errorf("KPrototypes.NumericScales = %v, want [100]", kp.NumericScales)
+	}
+
+	// Labels of single rows must not depend on the batch they are predicted
+	// in.
+	for i := 0; i < 6; i++ {
+		got, err := kp.Predict(NewDenseMatrix(1, 2, X.RawRowView(i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.At(0, 0) != kp.Labels.At(i, 0) {
+			t.Errorf("KPrototypes.Predict() row %d = %v, want %v", i, got.At(0, 0), kp.Labels.At(i, 0))
+		}
+	}
+
+	// Scales which do not match numeric attributes are not replaced by the
+	// maximum of the batch.
+	kp.NumericScales = []float64{100, 1}
+	if _, err := kp.Predict(NewDenseMatrix(1, 2, X.RawRowView(0))); err == nil {
+		t.Error("KPrototypes.Predict() with wrong number of numeric scales, want error")
+	}
+	if _, err := kp.Transform(NewDenseMatrix(1, 2, X.RawRowView(0))); err == nil {
+		t.Error("KPrototypes.Transform() with wrong number of numeric scales, want error")
+	}
+}
+
+func TestKPrototypes_Transform(t *testing.T) {
+	X := NewDenseMatrix(6, 2, []float64{
+		1, 10,
+		1, 20,
+		1, 30,
+		2, 70,
+		2, 80,
+		2, 100,
+	})
+	kp := NewKPrototypes(HammingDistance, InitCao, []int{0}, 2, 1, 10, [][]float64{{1}}, 0.5, "")
+	kp.Seed = 1
+	if err := kp.FitModel(NewDenseMatrix(6, 2, append([]float64(nil), X.RawMatrix().Data...))); err != nil {
+		t.Fatal(err)
+	}
+
+	distances, err := kp.Transform(X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		for c := 0; c < 2; c++ {
+			want := math.Abs(X.At(i, 1)/100 - kp.ClusterCentroidsNum.At(c, 0))
+			if X.At(i, 0) != kp.ClusterCentroidsCat.At(c, 0) {
+				want += 0.5
+			}
+			if got := distances.At(i, c); math.Abs(got-want) > 1e-12 {
+				t.Errorf("KPrototypes.Transform() distance(%d, %d) = %v, want %v", i, c, got, want)
+			}
+		}
+	}
+}
+
+func TestKPrototypes_LoadModel(t *testing.T) {
+	X := NewDenseMatrix(6, 3, []float64{
+		10, 1, 5,
+		20, 1, 5,
+		30, 1, 5,
+		70, 2, 6,
+		80, 2, 6,
+		100, 2, 6,
+	})
+	dir := t.TempDir()
+	kp := NewKPrototypes(HammingDistance, InitCao, []int{1, 2}, 2, 1, 10, [][]float64{{1, 1}}, 1, filepath.Join(dir, "kp.txt"))
+	kp.Seed = 1
+	if err := kp.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		want := []float64{kp.ClusterCentroidsNum.At(i, 0), kp.ClusterCentroidsCat.At(i, 0), kp.ClusterCentroidsCat.At(i, 1)}
+		if got := kp.ClusterCentroids.RawRowView(i); !reflect.DeepEqual(got, want) {
+			t.Errorf("KPrototypes.ClusterCentroids row %d = %v, want %v", i, got, want)
+		}
+	}
+	if err := kp.SaveModel(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewKPrototypes(HammingDistance, nil, nil, 0, 0, 0, nil, 0, kp.ModelPath)
+	if err := loaded.LoadModel(); err != nil {
+		t.Fatalf("KPrototypes.LoadModel() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded.ClusterCentroids, kp.ClusterCentroids) || !reflect.DeepEqual(loaded.NumericScales, kp.NumericScales) {
+		t.Errorf("KPrototypes.LoadModel() loaded different model")
+	}
+
+	missing := NewKPrototypes(HammingDistance, nil, nil, 0, 0, 0, nil, 0, filepath.Join(dir, "missing.txt"))
+	if err := missing.LoadModel(); err == nil {
+		t.Errorf("KPrototypes.LoadModel() of missing file did not fail")
+	}
+}
+
+func BenchmarkKPrototypes_FitModel(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	rows, cols := 5000, 6
+	data := make([]float64, rows*cols)
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			if j < 3 {
+				data[i*cols+j] = float64(rnd.Intn(10))
+			} else {
+				data[i*cols+j] = rnd.Float64() * 100
+			}
+		}
+	}
+	X := NewDenseMatrix(rows, cols, data)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		kp := NewKPrototypes(HammingDistance, InitRandom, []int{0, 1, 2}, 50, 1, 5, [][]float64{{1, 1, 1}}, 1, "")
+		kp.Seed = 1
+		if err := kp.FitModel(X); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ClusterMatching aligns clusters of an old clustering with clusters of a new
+// one, e.g. of a model refitted on next month's data.
+type ClusterMatching struct {
+	Permutation []int     // Permutation[i] is the new cluster matched to old cluster i, -1 if it died
+	Similarity  []float64 // Similarity[i] is the similarity of old cluster i to its match, 0 if it died
+	Born        []int     // new clusters without a match
+	Died        []int     // old clusters without a match
+}
+
+// MatchLabels matches clusters of two label vectors of the same rows by their
+// overlap. Similarity of two clusters is the Jaccard index of their rows, rows
+// with negative labels (noise) are skipped. Pairs are chosen to maximize the
+// total similarity, a pair is kept only if its similarity is positive and at
+// least minSimilarity.
+func MatchLabels(old, new *DenseVector, minSimilarity float64) (*ClusterMatching, error) {
+	if old.Len() != new.Len() {
+		return nil, errors.New("match labels: label vectors have different lengths")
+	}
+	oldClusters, newClusters := labelsClusters(old), labelsClusters(new)
+	oldCounts, newCounts := make([]float64, oldClusters), make([]float64, newClusters)
+	common := make([][]float64, oldClusters)
+	for i := range common {
+		common[i] = make([]float64, newClusters)
+	}
+	for i := 0; i < old.Len(); i++ {
+		o, n := int(old.At(i, 0)), int(new.At(i, 0))
+		if o >= 0 {
+			oldCounts[o]++
+		}
+		if n >= 0 {
+			newCounts[n]++
+		}
+		if o >= 0 && n >= 0 {
+			common[o][n]++
+		}
+	}
+
+	similarity := common
+	for i, row := range similarity {
+		for j, c := range row {
+			if c > 0 {
+				row[j] = c / (oldCounts[i] + newCounts[j] - c)
+			}
+		}
+	}
+	return matchClusters(similarity, oldClusters, newClusters, minSimilarity), nil
+}
+
+// MatchCentroids matches clusters of two models by distances of their
+// centroids, e.g. CentroidRows of the old and of the new model. Similarity of
+// two clusters is 1 / (1 + d), where d is the distance of their centroids.
+// Pairs are chosen to maximize the total similarity, a pair is kept only if
+// its similarity is at least minSimilarity. Nil centroids, e.g. of dropped
+// clusters, are left out of the matching: they die or are born.
+func MatchCentroids(old, new [][]float64, dist DistanceFunction, minSimilarity float64) (*ClusterMatching, error) {
+	oldLive, newLive := liveClusters(old), liveClusters(new)
+	similarity := make([][]float64, len(oldLive))
+	for i, oi := range oldLive {
+		similarity[i] = make([]float64, len(newLive))
+		o := old[oi]
+		for j, ni := range newLive {
+			n := new[ni]
+			if len(o) != len(n) {
+				return nil, fmt.Errorf("match centroids: old centroid %d has %d attributes, new centroid %d has %d", oi, len(o), ni, len(n))
+			}
+			d, err := dist(NewDenseVector(len(o), o), NewDenseVector(len(n), n))
+			if err != nil {
+				return nil, fmt.Errorf("match centroids: %v", err)
+			}
+			similarity[i][j] = 1 / (1 + d)
+		}
+	}
+	m := matchClusters(similarity, len(oldLive), len(newLive), minSimilarity)
+	return expandMatching(m, oldLive, newLive, len(old), len(new)), nil
+}
+
+// CentroidModel is a fitted model whose clusters are matched by centroids,
+// it is implemented by KModes and KPrototypes.
+type CentroidModel interface {
+	CentroidRows() [][]float64
+	IsDropped(cluster int) bool
+}
+
+// MatchModels matches clusters of two fitted models by distances of their
+// centroids like MatchCentroids. Dropped clusters are left out of the
+// matching, so they die in the old model and are born in the new one.
+func MatchModels(old, new CentroidModel, dist DistanceFunction, minSimilarity float64) (*ClusterMatching, error) {
+	return MatchCentroids(modelCentroids(old), modelCentroids(new), dist, minSimilarity)
+}
+
+// modelCentroids returns centroids of the model, which are nil for dropped
+// clusters.
+func modelCentroids(m CentroidModel) [][]float64 {
+	rows := m.CentroidRows()
+	for i := range rows {
+		if m.IsDropped(i) {
+			rows[i] = nil
+		}
+	}
+	return rows
+}
+
+// liveClusters returns indexes of centroids which are not nil.
+func liveClusters(centroids [][]float64) []int {
+	live := make([]int, 0, len(centroids))
+	for i, c := range centroids {
+		if c != nil {
+			live = append(live, i)
+		}
+	}
+	return live
+}
+
+// expandMatching translates matching m of live clusters back to indexes of
+// all old and new clusters, clusters left out of m die or are born.
+func expandMatching(m *ClusterMatching, oldLive, newLive []int, oldClusters, newClusters int) *ClusterMatching {
+	e := &ClusterMatching{
+		Permutation: make([]int, oldClusters),
+		Similarity:  make([]float64, oldClusters),
+	}
+	for i := range e.Permutation {
+		e.Permutation[i] = -1
+	}
+	matched := make([]bool, newClusters)
+	for i, j := range m.Permutation {
+		if j >= 0 {
+			e.Permutation[oldLive[i]], e.Similarity[oldLive[i]] = newLive[j], m.Similarity[i]
+			matched[newLive[j]] = true
+		}
+	}
+	for i, j := range e.Permutation {
+		if j == -1 {
+			e.Died = append(e.Died, i)
+		}
+	}
+	for j, ok := range matched {
+		if !ok {
+			e.Born = append(e.Born, j)
+		}
+	}
+	return e
+}
+
+// labelsClusters returns the number of clusters of labels, i.e. the highest
+// label plus one.
+func labelsClusters(labels *DenseVector) int {
+	var clusters int
+	for i := 0; i < labels.Len(); i++ {
+		if l := int(labels.At(i, 0)); l >= clusters {
+			clusters = l + 1
+		}
+	}
+	return clusters
+}
+
+// matchClusters finds the matching of old and new clusters with the highest
+// total similarity and drops pairs below minSimilarity.
+func matchClusters(similarity [][]float64, oldClusters, newClusters int, minSimilarity float64) *ClusterMatching {
+	// The assignment problem needs a square matrix, dummy clusters have
+	// similarity 0 to all others.
+	n := oldClusters
+	if newClusters > n {
+		n = newClusters
+	}
+	cost := make([][]float64, n)
+	for i := range cost {
+		cost[i] = make([]float64, n)
+		if i < oldClusters {
+			for j := 0; j < newClusters; j++ {
+				cost[i][j] = -similarity[i][j]
+			}
+		}
+	}
+	assignment := hungarian(cost)
+
+	m := &ClusterMatching{
+		Permutation: make([]int, oldClusters),
+		Similarity:  make([]float64, oldClusters),
+	}
+	matched := make([]bool, newClusters)
+	for i := 0; i < oldClusters; i++ {
+		j := assignment[i]
+		if j >= newClusters || similarity[i][j] <= 0 || similarity[i][j] < minSimilarity {
+			m.Permutation[i] = -1
+			m.Died = append(m.Died, i)
+			continue
+		}
+		m.Permutation[i], m.Similarity[i] = j, similarity[i][j]
+		matched[j] = true
+	}
+	for j, ok := range matched {
+		if !ok {
+			m.Born = append(m.Born, j)
+		}
+	}
+	return m
+}
+
+// hungarian solves the assignment problem for a square cost matrix with the
+// Hungarian algorithm in O(n^3) and returns the column assigned to every row.
+func hungarian(cost [][]float64) []int {
+	n := len(cost)
+	// Potentials u of rows and v of columns, p[j] is the row assigned to
+	// column j; row and column 0 are sentinels, so indices are shifted by one.
+	u, v := make([]float64, n+1), make([]float64, n+1)
+	p, way := make([]int, n+1), make([]int, n+1)
+	minv := make([]float64, n+1)
+	used := make([]bool, n+1)
+	for i := 1; i <= n; i++ {
+		p[0] = i
+		j0 := 0
+		for j := range minv {
+			minv[j], used[j] = math.Inf(1), false
+		}
+		for p[j0] != 0 {
+			used[j0] = true
+			i0, delta, j1 := p[j0], math.Inf(1), 0
+			for j := 1; j <= n; j++ {
+				if used[j] {
+					continue
+				}
+				if cur := cost[i0-1][j-1] - u[i0] - v[j]; cur < minv[j] {
+					minv[j], way[j] = cur, j0
+				}
+				if minv[j] < delta {
+					delta, j1 = minv[j], j
+				}
+			}
+			for j := 0; j <= n; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+		}
+		// Augment along the alternating path.
+		for j0 != 0 {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+		}
+	}
+
+	assignment := make([]int, n)
+	for j := 1; j <= n; j++ {
+		assignment[p[j]-1] = j - 1
+	}
+	return assignment
+}
@@ -0,0 +1,150 @@
+package cluster
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHungarian(t *testing.T) {
+	tests := []struct {
+		cost [][]float64
+		want []int
+	}{
+		{nil, []int{}},
+		{[][]float64{{5}}, []int{0}},
+		{[][]float64{
+			{4, 1, 3},
+			{2, 0, 5},
+			{3, 2, 2},
+		}, []int{1, 0, 2}},
+		{[][]float64{
+			{9, 2, 7, 8},
+			{6, 4, 3, 7},
+			{5, 8, 1, 8},
+			{7, 6, 9, 4},
+		}, []int{1, 0, 2, 3}},
+	}
+	for i, tt := range tests {
+		if got := hungarian(tt.cost); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d. hungarian() = %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestMatchLabels(t *testing.T) {
+	old := NewDenseVector(8, []float64{0, 0, 0, 1, 1, 2, 2, -1})
+	tests := []struct {
+		new           []float64
+		minSimilarity float64
+		want          *ClusterMatching
+	}{
+		// Same clusters with other IDs.
+		{[]float64{2, 2, 2, 0, 0, 1, 1, -1}, 0, &ClusterMatching{
+			Permutation: []int{2, 0, 1},
+			Similarity:  []float64{1, 1, 1},
+		}},
+		// Clusters 1 and 2 merged into a new cluster, a new cluster is born.
+		{[]float64{0, 0, 0, 1, 1, 1, 1, 2}, 0, &ClusterMatching{
+			Permutation: []int{0, 1, -1},
+			Similarity:  []float64{1, 0.5, 0},
+			Born:        []int{2},
+			Died:        []int{2},
+		}},
+		{[]float64{0, 0, 0, 1, 1, 1, 1, 2}, 0.6, &ClusterMatching{
+			Permutation: []int{0, -1, -1},
+			Similarity:  []float64{1, 0, 0},
+			Born:        []int{1, 2},
+			Died:        []int{1, 2},
+		}},
+	}
+	for i, tt := range tests {
+		got, err := MatchLabels(old, NewDenseVector(len(tt.new), tt.new), tt.minSimilarity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d. MatchLabels() = %+v, want %+v", i, got, tt.want)
+		}
+	}
+	if _, err := MatchLabels(old, NewDenseVector(2, nil), 0); err == nil {
+		t.Error("MatchLabels() with different lengths, want error")
+	}
+}
+
+func TestMatchCentroids(t *testing.T) {
+	old := [][]float64{{0, 0, 0}, {1, 1, 1}}
+	new := [][]float64{{1, 1, 0}, {2, 2, 2}, {0, 0, 1}}
+	got, err := MatchCentroids(old, new, HammingDistance, 0.4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ClusterMatching{
+		Permutation: []int{2, 0},
+		Similarity:  []float64{0.5, 0.5},
+		Born:        []int{1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MatchCentroids() = %+v, want %+v", got, want)
+	}
+
+	// Refitted models give similar clusters in another order, ties of
+	// distances are resolved differently.
+	X := randomCategorical(100, 5, 3, 8)
+	a := NewKModes(HammingDistance, InitHuang, 4, 1, 50, [][]float64{{1}}, "")
+	a.Seed = 2
+	if err := a.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+	rows := a.CentroidRows()
+	b := NewKModes(HammingDistance, nil, 4, 1, 50, [][]float64{{1}}, "")
+	b.InitialCentroids = [][]float64{rows[3], rows[1], rows[0], rows[2]}
+	if err := b.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+	got, err = MatchCentroids(a.CentroidRows(), b.CentroidRows(), HammingDistance, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{2, 1, 3, 0}; !reflect.DeepEqual(got.Permutation, want) {
+		t.Errorf("MatchCentroids() of refitted models = %v, want %v", got.Permutation, want)
+	}
+
+	if _, err := MatchCentroids(old, [][]float64{{1}}, HammingDistance, 0); err == nil {
+		t.Error("MatchCentroids() with different attributes, want error")
+	}
+}
+
+func TestMatchModels(t *testing.T) {
+	// Dropped clusters keep stale centroids, which must not absorb the new
+	// cluster 0 or hide the death of old cluster 1.
+	old := &KModes{ClusterCentroids: NewDenseMatrix(3, 3, []float64{0, 0, 0, 1, 1, 1, 2, 2, 2}), DroppedClusters: []int{2}}
+	new := &KModes{ClusterCentroids: NewDenseMatrix(3, 3, []float64{2, 2, 2, 0, 0, 0, 1, 1, 1}), DroppedClusters: []int{2}}
+	got, err := MatchModels(old, new, HammingDistance, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ClusterMatching{
+		Permutation: []int{1, -1, -1},
+		Similarity:  []float64{1, 0, 0},
+		Born:        []int{0, 2},
+		Died:        []int{1, 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MatchModels() = %+v, want %+v", got, want)
+	}
+
+	kp := &KPrototypes{ClusterCentroids: NewDenseMatrix(2, 1, []float64{1, 2}), CategoricalInd: []int{0}, DroppedClusters: []int{0}}
+	got, err = MatchModels(kp, kp, HammingDistance, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = &ClusterMatching{
+		Permutation: []int{-1, 1},
+		Similarity:  []float64{0, 1},
+		Born:        []int{0},
+		Died:        []int{0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MatchModels() of KPrototypes = %+v, want %+v", got, want)
+	}
+}
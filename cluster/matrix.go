@@ -27,3 +27,21 @@ type DenseMatrix struct {
 func NewDenseMatrix(r, c int, data []float64) *DenseMatrix {
 	return &DenseMatrix{Dense: mat.NewDense(r, c, data)}
 }
+
+// Matrix is a read-only dataset accepted by KModes and initialization
+// functions. It is implemented by DenseMatrix and CategoricalMatrix.
+type Matrix interface {
+	// Dims returns the number of rows and columns.
+	Dims() (r, c int)
+	// At returns the value of element at row i and column j.
+	At(i, j int) float64
+	// RowTo returns values of row i. Implementations may copy them into dst,
+	// which must have c elements, or return a view of their own storage, so
+	// the returned slice must not be modified.
+	RowTo(dst []float64, i int) []float64
+}
+
+// RowTo returns a view of row i, dst is not used.
+func (m *DenseMatrix) RowTo(dst []float64, i int) []float64 {
+	return m.RawRowView(i)
+}
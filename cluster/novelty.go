@@ -0,0 +1,113 @@
+package cluster
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// NoveltyLabel is the label Predict gives to rows which are farther from their
+// nearest centroid than allowed by NoveltyQuantile.
+const NoveltyLabel = -1
+
+// quantileLevels is the number of intervals of the grid of levels at which
+// distance quantiles are stored: 0, 0.01, ..., 1.
+const quantileLevels = 100
+
+// distanceQuantiles computes for every cluster quantiles of distances of its
+// members to the centroid at levels 0, 1/quantileLevels, ..., 1. If weights is
+// not nil, a row of weight w counts as w rows. Empty clusters get nil.
+func distanceQuantiles(labels *DenseVector, distances, weights []float64, clusters int) [][]float64 {
+	members := make([][]int, clusters)
+	for i := range distances {
+		c := int(labels.At(i, 0))
+		members[c] = append(members[c], i)
+	}
+	quantiles := make([][]float64, clusters)
+	for c, m := range members {
+		if len(m) == 0 {
+			continue
+		}
+		sort.SliceStable(m, func(a, b int) bool {
+			return distances[m[a]] < distances[m[b]]
+		})
+		sorted := make([]float64, len(m))
+		var sortedWeights []float64
+		if weights != nil {
+			sortedWeights = make([]float64, len(m))
+		}
+		for k, i := range m {
+			sorted[k] = distances[i]
+			if weights != nil {
+				sortedWeights[k] = weights[i]
+			}
+		}
+		quantiles[c] = make([]float64, quantileLevels+1)
+		for l := range quantiles[c] {
+			level := float64(l) / quantileLevels
+			if weights == nil {
+				quantiles[c][l] = quantile(sorted, level)
+			} else {
+				quantiles[c][l] = weightedQuantile(sorted, sortedWeights, level)
+			}
+		}
+	}
+	return quantiles
+}
+
+// quantile returns the quantile of sorted values at the level, linearly
+// interpolated between closest ranks.
+func quantile(sorted []float64, level float64) float64 {
+	pos := level * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	if lower >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lower] + (pos-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
+
+// weightedQuantile returns the quantile at the level of sorted values with
+// the given weights. A value of weight w takes w ranks, so for integer weights
+// the result equals quantile of values repeated according to their weights.
+func weightedQuantile(sorted, weights []float64, level float64) float64 {
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	// rank returns the value at the given rank.
+	rank := func(r float64) float64 {
+		var cum float64
+		for k, w := range weights {
+			cum += w
+			if cum > r {
+				return sorted[k]
+			}
+		}
+		return sorted[len(sorted)-1]
+	}
+	pos := math.Max(level*(total-1), 0)
+	lower := math.Floor(pos)
+	return rank(lower) + (pos-lower)*(rank(lower+1)-rank(lower))
+}
+
+// noveltyThreshold returns the largest distance from centroid of the cluster
+// which is not novel, quantiles are interpolated between stored levels.
+func noveltyThreshold(quantiles [][]float64, cluster int, level float64) float64 {
+	if cluster >= len(quantiles) || quantiles[cluster] == nil {
+		return math.Inf(1)
+	}
+	return quantile(quantiles[cluster], level)
+}
+
+// checkNoveltyQuantile validates the quantile used by Predict, distance
+// quantiles must have been recorded if it is set. Quantiles are in (0, 1], 0
+// disables novelty detection, so the 0 quantile cannot be requested.
+func checkNoveltyQuantile(level float64, quantiles [][]float64) error {
+	if level < 0 || level > 1 {
+		return fmt.Errorf("novelty quantile %v out of range (0, 1], 0 disables novelty detection", level)
+	}
+	if level > 0 && quantiles == nil {
+		return fmt.Errorf("novelty quantile is set, but the model has no distance quantiles")
+	}
+	return nil
+}
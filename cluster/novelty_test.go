@@ -0,0 +1,123 @@
+package cluster
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQuantile(t *testing.T) {
+	sorted := []float64{1, 2, 4, 8}
+	tests := []struct {
+		level float64
+		want  float64
+	}{
+		{0, 1},
+		{1, 8},
+		{0.5, 3},
+		{1.0 / 3, 2},
+		{0.9, 6.8},
+	}
+	for _, tt := range tests {
+		if got := quantile(sorted, tt.level); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("quantile(%v) = %v, want %v", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestDistanceQuantiles(t *testing.T) {
+	labels := NewDenseVector(5, []float64{0, 2, 0, 0, 2})
+	q := distanceQuantiles(labels, []float64{3, 1, 1, 2, 1}, nil, 3)
+	if q[1] != nil {
+		t.Errorf("distanceQuantiles() of empty cluster = %v, want nil", q[1])
+	}
+	if len(q[0]) != quantileLevels+1 || q[0][0] != 1 || q[0][50] != 2 || q[0][quantileLevels] != 3 {
+		t.Errorf("distanceQuantiles() cluster 0 = %v", q[0])
+	}
+	if got := noveltyThreshold(q, 1, 0.5); !math.IsInf(got, 1) {
+		t.Errorf("noveltyThreshold() of empty cluster = %v, want +Inf", got)
+	}
+	if got := noveltyThreshold(q, 0, 0.75); got != 2.5 {
+		t.Errorf("noveltyThreshold() = %v, want 2.5", got)
+	}
+}
+
+func TestKModes_PredictNovelty(t *testing.T) {
+	X := NewDenseMatrix(6, 3, []float64{
+		0, 0, 0,
+		0, 0, 1,
+		0, 0, 0,
+		1, 1, 1,
+		1, 1, 0,
+		1, 1, 1,
+	})
+	km := NewKModes(HammingDistance, InitCao, 2, 1, 10, [][]float64{{1}}, "")
+	if err := km.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+	if len(km.DistanceQuantiles) != 2 {
+		t.Fatalf("FitModel() recorded %d distance quantiles, want 2", len(km.DistanceQuantiles))
+	}
+
+	km.NoveltyQuantile = 1
+	labels, err := km.Predict(X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		if labels.At(i, 0) == NoveltyLabel {
+			t.Errorf("training row %d is flagged as novel", i)
+		}
+	}
+	labels, err = km.Predict(NewDenseMatrix(2, 3, []float64{2, 2, 2, 0, 0, 0}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if labels.At(0, 0) != NoveltyLabel || labels.At(1, 0) == NoveltyLabel {
+		t.Errorf("Predict() = %v, want only the first row novel", labels.RawVector().Data)
+	}
+
+	for _, q := range []float64{-0.1, 1.5} {
+		km.NoveltyQuantile = q
+		if _, err := km.Predict(X); err == nil {
+			t.Errorf("Predict() with novelty quantile %v, want error", q)
+		}
+	}
+	km.NoveltyQuantile, km.DistanceQuantiles = 0.9, nil
+	if _, err := km.Predict(X); err == nil {
+		t.Error("Predict() without distance quantiles, want error")
+	}
+}
+
+func TestKPrototypes_PredictNovelty(t *testing.T) {
+	data := []float64{
+		1, 10,
+		1, 20,
+		1, 30,
+		2, 70,
+		2, 80,
+		2, 100,
+	}
+	kp := NewKPrototypes(HammingDistance, InitCao, []int{0}, 2, 1, 10, [][]float64{{1}}, 0.5, "")
+	kp.Seed = 1
+	if err := kp.FitModel(NewDenseMatrix(6, 2, append([]float64(nil), data...))); err != nil {
+		t.Fatal(err)
+	}
+
+	kp.NoveltyQuantile = 1
+	labels, err := kp.Predict(NewDenseMatrix(6, 2, append([]float64(nil), data...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		if labels.At(i, 0) == NoveltyLabel {
+			t.Errorf("training row %d is flagged as novel", i)
+		}
+	}
+	labels, err = kp.Predict(NewDenseMatrix(2, 2, []float64{1, 20, 1, 60}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if labels.At(0, 0) == NoveltyLabel || labels.At(1, 0) != NoveltyLabel {
+		t.Errorf("Predict() = %v, want only the second row novel", labels.RawVector().Data)
+	}
+}
@@ -0,0 +1,150 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// OPTICS is a basic class for the OPTICS algorithm. It orders rows so that
+// rows of dense regions are close to each other and records reachability
+// distances, whose plot in Ordering shows clusters as valleys. Clusters as
+// found by DBSCAN with any eps not larger than MaxEps are extracted with
+// ExtractDBSCAN.
+type OPTICS struct {
+	DistanceFunc  DistanceFunction
+	MaxEps        float64   // largest distance of rows in a neighborhood, +Inf for no limit
+	MinSamples    int       // number of rows in a neighborhood of a core row, including the row
+	Ordering      []int     // cluster order of rows
+	Reachability  []float64 // reachability distance of every row, +Inf for the first rows of regions
+	CoreDistances []float64 // distance of every row to its MinSamples-th nearest row, +Inf if larger than MaxEps
+	Predecessor   []int     // row from which every row was reached, -1 for the first rows of regions
+	IsFitted      bool
+}
+
+// NewOPTICS implements constructor for the OPTICS struct.
+func NewOPTICS(dist DistanceFunction, maxEps float64, minSamples int) *OPTICS {
+	return &OPTICS{
+		DistanceFunc: dist,
+		MaxEps:       maxEps,
+		MinSamples:   minSamples,
+	}
+}
+
+// FitModel orders rows of X using DistanceFunc. Distances are computed on the
+// fly, every pair twice, without storing the distance matrix.
+func (op *OPTICS) FitModel(X *DenseMatrix) error {
+	if op.DistanceFunc == nil {
+		return errors.New("optics: distance function is not set")
+	}
+	xRows, _ := X.Dims()
+	return op.fit(xRows, dataRowDistances(X, op.DistanceFunc))
+}
+
+// FitDistances orders rows of the dataset described by distance matrix D.
+func (op *OPTICS) FitDistances(D DistanceMatrix) error {
+	return op.fit(D.Len(), matrixRowDistances(D))
+}
+
+func (op *OPTICS) fit(n int, distances rowDistances) error {
+	if op.MaxEps < 0 || op.MinSamples < 1 {
+		return errors.New("optics: wrong initialization parameters (max eps should be >=0, min samples >=1)")
+	}
+	if n < op.MinSamples {
+		return fmt.Errorf("optics: min samples %d is larger than the number of rows %d", op.MinSamples, n)
+	}
+	op.IsFitted = false
+
+	row := make([]float64, n)
+	sorted := make([]float64, n)
+	op.CoreDistances = make([]float64, n)
+	for i := 0; i < n; i++ {
+		if err := distances(i, row); err != nil {
+			return fmt.Errorf("optics: %v", err)
+		}
+		copy(sorted, row)
+		sort.Float64s(sorted)
+		op.CoreDistances[i] = sorted[op.MinSamples-1]
+		if op.CoreDistances[i] > op.MaxEps {
+			op.CoreDistances[i] = math.Inf(1)
+		}
+	}
+
+	op.Reachability = make([]float64, n)
+	op.Predecessor = make([]int, n)
+	for i := range op.Reachability {
+		op.Reachability[i], op.Predecessor[i] = math.Inf(1), -1
+	}
+	op.Ordering = make([]int, 0, n)
+	processed := make([]bool, n)
+	for len(op.Ordering) < n {
+		// The unprocessed row with the smallest reachability is next, ties
+		// (also rows starting new regions) are resolved by index.
+		p := -1
+		for i, done := range processed {
+			if !done && (p < 0 || op.Reachability[i] < op.Reachability[p]) {
+				p = i
+			}
+		}
+		processed[p] = true
+		op.Ordering = append(op.Ordering, p)
+		if math.IsInf(op.CoreDistances[p], 1) {
+			continue
+		}
+		if err := distances(p, row); err != nil {
+			return fmt.Errorf("optics: %v", err)
+		}
+		for q, d := range row {
+			if processed[q] || d > op.MaxEps {
+				continue
+			}
+			if r := math.Max(op.CoreDistances[p], d); r < op.Reachability[q] {
+				op.Reachability[q], op.Predecessor[q] = r, p
+			}
+		}
+	}
+	op.IsFitted = true
+	return nil
+}
+
+// ReachabilityPlot returns reachability distances in the cluster order.
+// Valleys of the plot are clusters, the height of the border between valleys
+// is the eps which separates them.
+func (op *OPTICS) ReachabilityPlot() []float64 {
+	plot := make([]float64, len(op.Ordering))
+	for i, p := range op.Ordering {
+		plot[i] = op.Reachability[p]
+	}
+	return plot
+}
+
+// ExtractDBSCAN labels rows as DBSCAN with the given eps and the same
+// MinSamples would, eps must not be larger than MaxEps. Core rows and noise
+// match DBSCAN, border rows may be assigned to another neighboring cluster or
+// labelled as noise. It returns labels, NoiseLabel for noise, and the number
+// of clusters.
+func (op *OPTICS) ExtractDBSCAN(eps float64) (*DenseVector, int, error) {
+	if !op.IsFitted {
+		return nil, 0, errors.New("optics: cannot extract clusters, model is not fitted yet")
+	}
+	if eps < 0 || eps > op.MaxEps {
+		return nil, 0, fmt.Errorf("optics: eps %v out of range [0, %v]", eps, op.MaxEps)
+	}
+	labels := NewDenseVector(len(op.Ordering), nil)
+	clusters := 0
+	for _, p := range op.Ordering {
+		switch {
+		case op.Reachability[p] <= eps:
+			// The predecessor is a core row within eps, which belongs to
+			// the current cluster.
+			labels.SetVec(p, float64(clusters-1))
+		case op.CoreDistances[p] <= eps:
+			labels.SetVec(p, float64(clusters))
+			clusters++
+		default:
+			labels.SetVec(p, NoiseLabel)
+		}
+	}
+	return labels, clusters, nil
+}
@@ -0,0 +1,96 @@
+package cluster
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestOPTICS_FitDistances(t *testing.T) {
+	op := NewOPTICS(nil, math.Inf(1), 2)
+	if _, _, err := op.ExtractDBSCAN(1); err == nil {
+		t.Error("ExtractDBSCAN() of not fitted model, want error")
+	}
+	if err := op.FitDistances(lineDistances(0, 1, 2, 10, 11, 12, 30)); err != nil {
+		t.Fatal(err)
+	}
+	inf := math.Inf(1)
+	if want := []int{0, 1, 2, 3, 4, 5, 6}; !reflect.DeepEqual(op.Ordering, want) {
+		t.Errorf("ordering = %v, want %v", op.Ordering, want)
+	}
+	if want := []float64{inf, 1, 1, 8, 1, 1, 18}; !reflect.DeepEqual(op.ReachabilityPlot(), want) {
+		t.Errorf("reachability plot = %v, want %v", op.ReachabilityPlot(), want)
+	}
+	if want := []float64{1, 1, 1, 1, 1, 1, 18}; !reflect.DeepEqual(op.CoreDistances, want) {
+		t.Errorf("core distances = %v, want %v", op.CoreDistances, want)
+	}
+	if want := []int{-1, 0, 1, 2, 3, 4, 5}; !reflect.DeepEqual(op.Predecessor, want) {
+		t.Errorf("predecessors = %v, want %v", op.Predecessor, want)
+	}
+
+	tests := []struct {
+		eps          float64
+		wantLabels   []float64
+		wantClusters int
+	}{
+		{0.5, []float64{-1, -1, -1, -1, -1, -1, -1}, 0},
+		{1.5, []float64{0, 0, 0, 1, 1, 1, -1}, 2},
+		{10, []float64{0, 0, 0, 0, 0, 0, -1}, 1},
+		{20, []float64{0, 0, 0, 0, 0, 0, 0}, 1},
+	}
+	for _, tt := range tests {
+		labels, clusters, err := op.ExtractDBSCAN(tt.eps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(labels.RawVector().Data, tt.wantLabels) || clusters != tt.wantClusters {
+			t.Errorf("ExtractDBSCAN(%v) = %v, %d, want %v, %d", tt.eps, labels.RawVector().Data, clusters, tt.wantLabels, tt.wantClusters)
+		}
+	}
+
+	op = NewOPTICS(nil, 5, 2)
+	if err := op.FitDistances(lineDistances(0, 1, 2, 10, 11, 12, 30)); err != nil {
+		t.Fatal(err)
+	}
+	if op.Reachability[3] != inf || op.CoreDistances[6] != inf {
+		t.Errorf("reachability = %v, core distances = %v, want +Inf beyond max eps", op.Reachability, op.CoreDistances)
+	}
+	if _, _, err := op.ExtractDBSCAN(6); err == nil {
+		t.Error("ExtractDBSCAN() above max eps, want error")
+	}
+	if err := NewOPTICS(nil, 1, 8).FitDistances(lineDistances(0, 1)); err == nil {
+		t.Error("FitDistances() with more min samples than rows, want error")
+	}
+}
+
+func TestOPTICS_MatchesDBSCAN(t *testing.T) {
+	X := randomCategorical(300, 6, 4, 4)
+	op := NewOPTICS(HammingDistance, 2, 3)
+	if err := op.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+	for _, eps := range []float64{1, 2} {
+		db := NewDBSCAN(HammingDistance, eps, 3)
+		if err := db.FitModel(X); err != nil {
+			t.Fatal(err)
+		}
+		labels, clusters, _ := op.ExtractDBSCAN(eps)
+		if clusters != db.ClustersNumber {
+			t.Errorf("eps %v: %d clusters, DBSCAN found %d", eps, clusters, db.ClustersNumber)
+		}
+		// Core rows form the same clusters, up to their numbering.
+		mapping := make(map[float64]float64)
+		for _, i := range db.CoreSamples {
+			want, got := db.Labels.At(i, 0), labels.At(i, 0)
+			if m, ok := mapping[want]; ok && m != got || got == NoiseLabel {
+				t.Fatalf("eps %v: core row %d in cluster %v, DBSCAN cluster %v", eps, i, got, want)
+			}
+			mapping[want] = got
+		}
+		for i := 0; i < 300; i++ {
+			if db.Labels.At(i, 0) == NoiseLabel && labels.At(i, 0) != NoiseLabel {
+				t.Errorf("eps %v: DBSCAN noise row %d is in cluster %v", eps, i, labels.At(i, 0))
+			}
+		}
+	}
+}
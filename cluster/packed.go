@@ -0,0 +1,136 @@
+package cluster
+
+import (
+	"math/bits"
+	"reflect"
+	"sort"
+)
+
+// packedEncoder encodes categorical rows as one-hot bitsets packed in uint64
+// words. Every attribute value seen in the dataset gets its own bit, so a row
+// has exactly one bit set per attribute and (weighted) Hamming distance
+// between two rows can be computed with popcount of their intersection.
+type packedEncoder struct {
+	values []map[float64]int // bit index of each value of each attribute
+	words  int               // number of uint64 words per row
+	groups []packedGroup     // attributes grouped by their weight
+}
+
+// packedGroup holds bits of all attributes which share the same weight.
+type packedGroup struct {
+	weight float64
+	count  float64 // number of attributes in the group
+	mask   []uint64
+}
+
+// newPackedEncoder creates encoder for dataset X. weights are attributes
+// weights, nil means that all attributes have weight 1.
+func newPackedEncoder(X Matrix, weights []float64) *packedEncoder {
+	xRows, xCols := X.Dims()
+	e := &packedEncoder{values: make([]map[float64]int, xCols)}
+
+	var bit int
+	offsets := make([]int, xCols+1)
+	for j := 0; j < xCols; j++ {
+		offsets[j] = bit
+		e.values[j] = make(map[float64]int)
+		for i := 0; i < xRows; i++ {
+			v := X.At(i, j)
+			if _, ok := e.values[j][v]; !ok {
+				e.values[j][v] = bit
+				bit++
+			}
+		}
+	}
+	offsets[xCols] = bit
+	e.words = (bit + 63) / 64
+
+	// Group attributes by weight, in order of first appearance.
+	index := make(map[float64]int)
+	for j := 0; j < xCols; j++ {
+		w := 1.0
+		if weights != nil {
+			w = weights[j]
+		}
+		g, ok := index[w]
+		if !ok {
+			g = len(e.groups)
+			index[w] = g
+			e.groups = append(e.groups, packedGroup{weight: w, mask: make([]uint64, e.words)})
+		}
+		e.groups[g].count++
+		for b := offsets[j]; b < offsets[j+1]; b++ {
+			e.groups[g].mask[b/64] |= 1 << uint(b%64)
+		}
+	}
+	sort.SliceStable(e.groups, func(a, b int) bool { return e.groups[a].weight < e.groups[b].weight })
+
+	return e
+}
+
+// encode packs row into dst, which must have e.words elements. Values which
+// were not seen in the dataset have no bit, so they never match.
+func (e *packedEncoder) encode(row []float64, dst []uint64) {
+	for i := range dst {
+		dst[i] = 0
+	}
+	for j, v := range row {
+		if b, ok := e.values[j][v]; ok {
+			dst[b/64] |= 1 << uint(b%64)
+		}
+	}
+}
+
+// encodeMatrix packs all rows of X into one slice, row i occupies words
+// [i*e.words, (i+1)*e.words).
+func (e *packedEncoder) encodeMatrix(X Matrix) []uint64 {
+	xRows, xCols := X.Dims()
+	packed := make([]uint64, xRows*e.words)
+	buf := make([]float64, xCols)
+	for i := 0; i < xRows; i++ {
+		e.encode(X.RowTo(buf, i), packed[i*e.words:(i+1)*e.words])
+	}
+	return packed
+}
+
+// fits checks whether packed rows of X take no more memory than X itself, so
+// that compact datasets are not inflated by packing.
+func (e *packedEncoder) fits(X Matrix) bool {
+	_, xCols := X.Dims()
+	rowBytes := 8 * xCols
+	if m, ok := X.(*CategoricalMatrix); ok {
+		rowBytes = m.rowBytes()
+	}
+	return 8*e.words <= rowBytes
+}
+
+// distance computes (weighted) Hamming distance between packed rows.
+func (e *packedEncoder) distance(a, b []uint64) float64 {
+	if len(e.groups) == 1 {
+		var matches int
+		for i, w := range a {
+			matches += bits.OnesCount64(w & b[i])
+		}
+		return e.groups[0].weight * (e.groups[0].count - float64(matches))
+	}
+	var distance float64
+	for _, g := range e.groups {
+		var matches int
+		for i, w := range a {
+			matches += bits.OnesCount64(w & b[i] & g.mask[i])
+		}
+		distance += g.weight * (g.count - float64(matches))
+	}
+	return distance
+}
+
+// isPackable checks whether distance function can be computed on packed rows.
+func isPackable(dist DistanceFunction) bool {
+	p := reflect.ValueOf(dist).Pointer()
+	return p == reflect.ValueOf(HammingDistance).Pointer() || p == reflect.ValueOf(WeightedHammingDistance).Pointer()
+}
+
+// isWeighted checks whether dist is WeightedHammingDistance.
+func isWeighted(dist DistanceFunction) bool {
+	return reflect.ValueOf(dist).Pointer() == reflect.ValueOf(WeightedHammingDistance).Pointer()
+}
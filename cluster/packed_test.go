@@ -0,0 +1,127 @@
+package cluster
+
+import (
+	"reflect"
+	"testing"
+)
+
+// unpackedHamming is HammingDistance hidden from automatic packing.
+func unpackedHamming(a, b *DenseVector) (float64, error) {
+	return HammingDistance(a, b)
+}
+
+// unpackedWeightedHamming is WeightedHammingDistance hidden from automatic
+// packing.
+func unpackedWeightedHamming(a, b *DenseVector) (float64, error) {
+	return WeightedHammingDistance(a, b)
+}
+
+func Test_packedEncoder_distance(t *testing.T) {
+	X := randomCategorical(50, 30, 4, 1)
+	_, xCols := X.Dims()
+	weights := make([]float64, xCols)
+	for i := range weights {
+		weights[i] = float64(i%3 + 1)
+	}
+
+	tests := []struct {
+		weights []float64
+		dist    RawDistanceFunction
+	}{
+		{weights: nil, dist: RawHammingDistance},
+		{weights: weights, dist: RawWeightedHammingDistance},
+	}
+	for _, tt := range tests {
+		if tt.weights != nil {
+			SetWeights(tt.weights)
+		}
+		e := newPackedEncoder(X, tt.weights)
+		packed := e.encodeMatrix(X)
+		for i := 0; i < 50; i++ {
+			for j := 0; j < 50; j++ {
+				want, _ := tt.dist(X.RawRowView(i), X.RawRowView(j))
+				got := e.distance(packed[i*e.words:(i+1)*e.words], packed[j*e.words:(j+1)*e.words])
+				if got != want {
+					t.Fatalf("packedEncoder.distance(%d, %d) = %v, want %v", i, j, got, want)
+				}
+			}
+		}
+	}
+
+	// Value not seen in the dataset never matches.
+	e := newPackedEncoder(NewDenseMatrix(2, 2, []float64{1, 1, 2, 2}), nil)
+	a, b := make([]uint64, e.words), make([]uint64, e.words)
+	e.encode([]float64{1, 3}, a)
+	e.encode([]float64{1, 3}, b)
+	if got := e.distance(a, b); got != 1 {
+		t.Errorf("packedEncoder.distance() with unknown value = %v, want 1", got)
+	}
+}
+
+func TestKModes_FitModelPacked(t *testing.T) {
+	X := randomCategorical(300, 12, 3, 2)
+	weights := []float64{1, 2, 1, 2, 1, 2, 3, 3, 1, 1, 1, 1}
+	// unpackedWeightedHamming calls WeightedHammingDistance directly, which
+	// does not know weights of the model.
+	SetWeights(weights)
+
+	tests := []struct {
+		packed, unpacked DistanceFunction
+	}{
+		{packed: HammingDistance, unpacked: unpackedHamming},
+		{packed: WeightedHammingDistance, unpacked: unpackedWeightedHamming},
+	}
+	for _, tt := range tests {
+		km1 := NewKModes(tt.packed, InitCao, 5, 1, 20, [][]float64{weights}, "")
+		km2 := NewKModes(tt.unpacked, InitCao, 5, 1, 20, [][]float64{weights}, "")
+		if err := km1.FitModel(X); err != nil {
+			t.Fatalf("KModes.FitModel() error = %v", err)
+		}
+		if err := km2.FitModel(X); err != nil {
+			t.Fatalf("KModes.FitModel() error = %v", err)
+		}
+		if !reflect.DeepEqual(km1.Labels, km2.Labels) || !reflect.DeepEqual(km1.ClusterCentroids, km2.ClusterCentroids) {
+			t.Errorf("KModes.FitModel() with packed rows differs from unpacked one")
+		}
+		if km1.Cost != km2.Cost {
+			t.Errorf("KModes.Cost = %v, want %v", km1.Cost, km2.Cost)
+		}
+	}
+}
+
+func benchmarkKModesWide(b *testing.B, dist DistanceFunction) {
+	X := randomCategorical(5000, 200, 4, 1)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		km := NewKModes(dist, InitRandom, 20, 1, 5, [][]float64{{1}}, "")
+		km.Seed = 1
+		if err := km.FitModel(X); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkKModes_FitModelWidePacked(b *testing.B) {
+	benchmarkKModesWide(b, HammingDistance)
+}
+
+func BenchmarkKModes_FitModelWideUnpacked(b *testing.B) {
+	benchmarkKModesWide(b, unpackedHamming)
+}
+
+func Benchmark_packedEncoder_distance(b *testing.B) {
+	X := randomCategorical(2, 200, 4, 1)
+	e := newPackedEncoder(X, nil)
+	packed := e.encodeMatrix(X)
+	for n := 0; n < b.N; n++ {
+		e.distance(packed[:e.words], packed[e.words:])
+	}
+}
+
+func BenchmarkRawHammingDistanceWide(b *testing.B) {
+	X := randomCategorical(2, 200, 4, 1)
+	for n := 0; n < b.N; n++ {
+		RawHammingDistance(X.RawRowView(0), X.RawRowView(1))
+	}
+}
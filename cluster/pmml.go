@@ -0,0 +1,283 @@
+package cluster
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// pmmlNamespace is the namespace of PMML 4.4 documents.
+const pmmlNamespace = "http://www.dmg.org/PMML-4_4"
+
+type pmmlDocument struct {
+	XMLName        xml.Name            `xml:"PMML"`
+	Xmlns          string              `xml:"xmlns,attr"`
+	Version        string              `xml:"version,attr"`
+	Header         pmmlHeader          `xml:"Header"`
+	DataDictionary pmmlDataDictionary  `xml:"DataDictionary"`
+	Model          pmmlClusteringModel `xml:"ClusteringModel"`
+}
+
+type pmmlHeader struct {
+	Description string          `xml:"description,attr"`
+	Application pmmlApplication `xml:"Application"`
+}
+
+type pmmlApplication struct {
+	Name string `xml:"name,attr"`
+}
+
+type pmmlDataDictionary struct {
+	NumberOfFields int             `xml:"numberOfFields,attr"`
+	Fields         []pmmlDataField `xml:"DataField"`
+}
+
+type pmmlDataField struct {
+	Name     string `xml:"name,attr"`
+	Optype   string `xml:"optype,attr"`
+	DataType string `xml:"dataType,attr"`
+}
+
+type pmmlClusteringModel struct {
+	ModelName         string                `xml:"modelName,attr"`
+	FunctionName      string                `xml:"functionName,attr"`
+	ModelClass        string                `xml:"modelClass,attr"`
+	NumberOfClusters  int                   `xml:"numberOfClusters,attr"`
+	MiningSchema      []pmmlMiningField     `xml:"MiningSchema>MiningField"`
+	Output            []pmmlOutputField     `xml:"Output>OutputField"`
+	ComparisonMeasure pmmlComparisonMeasure `xml:"ComparisonMeasure"`
+	ClusteringFields  []pmmlClusteringField `xml:"ClusteringField"`
+	Clusters          []pmmlCluster         `xml:"Cluster"`
+}
+
+type pmmlMiningField struct {
+	Name string `xml:"name,attr"`
+}
+
+type pmmlOutputField struct {
+	Name     string `xml:"name,attr"`
+	Optype   string `xml:"optype,attr"`
+	DataType string `xml:"dataType,attr"`
+	Feature  string `xml:"feature,attr"`
+}
+
+type pmmlComparisonMeasure struct {
+	Kind   string    `xml:"kind,attr"`
+	Metric pmmlEmpty `xml:",any"`
+}
+
+type pmmlEmpty struct {
+	XMLName xml.Name
+}
+
+type pmmlClusteringField struct {
+	Field           string  `xml:"field,attr"`
+	CompareFunction string  `xml:"compareFunction,attr"`
+	FieldWeight     float64 `xml:"fieldWeight,attr"`
+}
+
+type pmmlCluster struct {
+	ID    string    `xml:"id,attr"`
+	Name  string    `xml:"name,attr"`
+	Size  int       `xml:"size,attr"`
+	Array pmmlArray `xml:"Array"`
+}
+
+type pmmlArray struct {
+	N     int    `xml:"n,attr"`
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// ExportPMML writes the fitted model as PMML 4.4 ClusteringModel. fields are
+// names of attributes, if nil x0, x1, ... are used. Categorical attributes are
+// compared by simple matching (delta compare function) summed with city block
+// metric, weights of WeightedHammingDistance become field weights. Only
+// HammingDistance and WeightedHammingDistance can be exported. Dropped
+// clusters are left out, the rest keep their labels as ids.
+func (km *KModes) ExportPMML(w io.Writer, fields []string) error {
+	if !km.IsFitted {
+		return errors.New("kmodes: cannot export PMML, model is not fitted yet")
+	}
+	_, xCols := km.ClusterCentroids.Dims()
+	weights, err := pmmlCategoricalWeights(km.DistanceFunc, km.WeightVectors, xCols)
+	if err != nil {
+		return fmt.Errorf("kmodes: cannot export PMML: %v", err)
+	}
+	fields, err = attributeNames(fields, xCols)
+	if err != nil {
+		return fmt.Errorf("kmodes: cannot export PMML: %v", err)
+	}
+
+	doc := newPMMLDocument("k-modes", fields, "cityBlock")
+	for j, name := range fields {
+		doc.DataDictionary.Fields[j].Optype = "categorical"
+		doc.Model.ClusteringFields[j] = pmmlClusteringField{Field: name, CompareFunction: "delta", FieldWeight: weights[j]}
+	}
+	for i := 0; i < km.ClustersNumber; i++ {
+		if !isDropped(km.DroppedClusters, i) {
+			doc.addCluster(i, km.LabelsCounter[i], km.ClusterCentroids.RawRowView(i))
+		}
+	}
+	return doc.write(w)
+}
+
+// ExportPMML writes the fitted model as PMML 4.4 ClusteringModel. fields are
+// names of attributes in the column order of the data, if nil x0, x1, ... are
+// used. Categorical attributes are compared by simple matching (delta compare
+// function) with weights of WeightedHammingDistance multiplied by Gamma,
+// numerical ones by absolute difference weighted by the inverse square of
+// NumericScales, so centers are exported in original units.
+//
+// PMML aggregates all comparisons with a single metric, squaredEuclidean is
+// used. It gives the original k-prototypes cost of Huang (Gamma times
+// mismatches plus squared Euclidean distance), while Predict adds the plain
+// Euclidean distance, so rows close to cluster borders may be labelled
+// differently.
+func (km *KPrototypes) ExportPMML(w io.Writer, fields []string) error {
+	if !km.IsFitted {
+		return errors.New("kmodes: cannot export PMML, model is not fitted yet")
+	}
+	_, xCatCols := km.ClusterCentroidsCat.Dims()
+	_, xNumCols := km.ClusterCentroidsNum.Dims()
+	if len(km.NumericScales) != xNumCols {
+		return errors.New("kmodes: cannot export PMML, model has no numeric scales")
+	}
+	weights, err := pmmlCategoricalWeights(km.DistanceFunc, km.WeightVectors, xCatCols)
+	if err != nil {
+		return fmt.Errorf("kmodes: cannot export PMML: %v", err)
+	}
+	fields, err = attributeNames(fields, xCatCols+xNumCols)
+	if err != nil {
+		return fmt.Errorf("kmodes: cannot export PMML: %v", err)
+	}
+
+	doc := newPMMLDocument("k-prototypes", fields, "squaredEuclidean")
+	// Positions of attributes among categorical or numerical centroids.
+	isCat := make([]bool, len(fields))
+	positions := make([]int, len(fields))
+	var lastCat, lastNum int
+	for j, name := range fields {
+		if lastCat < xCatCols && km.CategoricalInd[lastCat] == j {
+			isCat[j], positions[j] = true, lastCat
+			doc.DataDictionary.Fields[j].Optype = "categorical"
+			doc.Model.ClusteringFields[j] = pmmlClusteringField{Field: name, CompareFunction: "delta", FieldWeight: km.Gamma * weights[lastCat]}
+			lastCat++
+			continue
+		}
+		positions[j] = lastNum
+		scale := km.NumericScales[lastNum]
+		doc.DataDictionary.Fields[j].Optype = "continuous"
+		doc.Model.ClusteringFields[j] = pmmlClusteringField{Field: name, CompareFunction: "absDiff", FieldWeight: 1 / (scale * scale)}
+		lastNum++
+	}
+
+	center := make([]float64, len(fields))
+	for i := 0; i < km.ClustersNumber; i++ {
+		if isDropped(km.DroppedClusters, i) {
+			continue
+		}
+		for j := range center {
+			if isCat[j] {
+				center[j] = km.ClusterCentroidsCat.At(i, positions[j])
+			} else {
+				center[j] = km.ClusterCentroidsNum.At(i, positions[j]) * km.NumericScales[positions[j]]
+			}
+		}
+		doc.addCluster(i, km.LabelsCounter[i], center)
+	}
+	return doc.write(w)
+}
+
+// pmmlCategoricalWeights returns weights of categorical attributes used by the
+// distance.
+func pmmlCategoricalWeights(dist DistanceFunction, weightVectors [][]float64, cols int) ([]float64, error) {
+	if !isPackable(dist) {
+		return nil, errors.New("only HammingDistance and WeightedHammingDistance can be exported")
+	}
+	weights := make([]float64, cols)
+	if !isWeighted(dist) {
+		for i := range weights {
+			weights[i] = 1
+		}
+		return weights, nil
+	}
+	if len(weightVectors) == 0 || len(weightVectors[0]) != cols {
+		return nil, fmt.Errorf("weight vector does not match %d categorical attributes", cols)
+	}
+	copy(weights, weightVectors[0])
+	return weights, nil
+}
+
+func attributeNames(fields []string, cols int) ([]string, error) {
+	if fields == nil {
+		fields = make([]string, cols)
+		for i := range fields {
+			fields[i] = "x" + strconv.Itoa(i)
+		}
+	}
+	if len(fields) != cols {
+		return nil, fmt.Errorf("got %d field names for %d attributes", len(fields), cols)
+	}
+	return fields, nil
+}
+
+func newPMMLDocument(name string, fields []string, metric string) *pmmlDocument {
+	doc := &pmmlDocument{
+		Xmlns:   pmmlNamespace,
+		Version: "4.4",
+		Header: pmmlHeader{
+			Description: name + " model",
+			Application: pmmlApplication{Name: "go-cluster"},
+		},
+		DataDictionary: pmmlDataDictionary{
+			NumberOfFields: len(fields),
+			Fields:         make([]pmmlDataField, len(fields)),
+		},
+		Model: pmmlClusteringModel{
+			ModelName:         name,
+			FunctionName:      "clustering",
+			ModelClass:        "centerBased",
+			MiningSchema:      make([]pmmlMiningField, len(fields)),
+			Output:            []pmmlOutputField{{Name: "cluster", Optype: "categorical", DataType: "string", Feature: "predictedValue"}},
+			ComparisonMeasure: pmmlComparisonMeasure{Kind: "distance", Metric: pmmlEmpty{XMLName: xml.Name{Local: metric}}},
+			ClusteringFields:  make([]pmmlClusteringField, len(fields)),
+		},
+	}
+	for j, name := range fields {
+		doc.DataDictionary.Fields[j] = pmmlDataField{Name: name, DataType: "double"}
+		doc.Model.MiningSchema[j] = pmmlMiningField{Name: name}
+	}
+	return doc
+}
+
+func (doc *pmmlDocument) addCluster(label, size int, center []float64) {
+	values := make([]string, len(center))
+	for j, v := range center {
+		values[j] = strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	id := strconv.Itoa(label)
+	doc.Model.Clusters = append(doc.Model.Clusters, pmmlCluster{
+		ID:    id,
+		Name:  id,
+		Size:  size,
+		Array: pmmlArray{N: len(center), Type: "real", Value: strings.Join(values, " ")},
+	})
+	doc.Model.NumberOfClusters = len(doc.Model.Clusters)
+}
+
+func (doc *pmmlDocument) write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,202 @@
+package cluster
+
+import (
+	"bytes"
+	"encoding/xml"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// parsePMML reads back a document written by ExportPMML.
+func parsePMML(t *testing.T, data []byte) (*pmmlDocument, [][]float64) {
+	var doc pmmlDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("cannot parse exported PMML: %v\n%s", err, data)
+	}
+	centers := make([][]float64, len(doc.Model.Clusters))
+	for i, c := range doc.Model.Clusters {
+		for _, s := range strings.Fields(c.Array.Value) {
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			centers[i] = append(centers[i], v)
+		}
+		if len(centers[i]) != c.Array.N {
+			t.Errorf("cluster %s has %d values, n = %d", c.ID, len(centers[i]), c.Array.N)
+		}
+	}
+	return &doc, centers
+}
+
+// scorePMML assigns the row to the nearest cluster as a PMML consumer would.
+func scorePMML(doc *pmmlDocument, centers [][]float64, row []float64) string {
+	best, bestDist := "", math.MaxFloat64
+	for i, center := range centers {
+		var dist float64
+		for j, f := range doc.Model.ClusteringFields {
+			var c float64
+			switch f.CompareFunction {
+			case "delta":
+				if row[j] != center[j] {
+					c = 1
+				}
+			case "absDiff":
+				c = math.Abs(row[j] - center[j])
+			}
+			if doc.Model.ComparisonMeasure.Metric.XMLName.Local == "squaredEuclidean" {
+				c *= c
+			}
+			dist += f.FieldWeight * c
+		}
+		if dist < bestDist {
+			best, bestDist = doc.Model.Clusters[i].ID, dist
+		}
+	}
+	return best
+}
+
+func TestKModes_ExportPMML(t *testing.T) {
+	X := randomCategorical(200, 4, 5, 7)
+	weights := []float64{1, 2, 0.5, 1}
+	for _, dist := range []DistanceFunction{HammingDistance, WeightedHammingDistance} {
+		km := NewKModes(dist, InitCao, 5, 1, 20, [][]float64{weights}, "")
+		km.Seed = 1
+		if err := km.FitModel(X); err != nil {
+			t.Fatal(err)
+		}
+		km.DroppedClusters = []int{4}
+
+		var buf bytes.Buffer
+		if err := km.ExportPMML(&buf, []string{"a", "b", "c", "d"}); err != nil {
+			t.Fatalf("KModes.ExportPMML() error = %v", err)
+		}
+		doc, centers := parsePMML(t, buf.Bytes())
+
+		if doc.Version != "4.4" || doc.Xmlns != pmmlNamespace || doc.Model.FunctionName != "clustering" || doc.Model.ModelClass != "centerBased" {
+			t.Errorf("KModes.ExportPMML() header = %+v", doc)
+		}
+		if doc.DataDictionary.NumberOfFields != 4 || doc.DataDictionary.Fields[1] != (pmmlDataField{Name: "b", Optype: "categorical", DataType: "double"}) {
+			t.Errorf("KModes.ExportPMML() data dictionary = %+v", doc.DataDictionary)
+		}
+		if got := doc.Model.ComparisonMeasure; got.Kind != "distance" || got.Metric.XMLName.Local != "cityBlock" {
+			t.Errorf("KModes.ExportPMML() comparison measure = %+v", got)
+		}
+		wantWeights := []float64{1, 1, 1, 1}
+		if isWeighted(dist) {
+			wantWeights = weights
+		}
+		for j, f := range doc.Model.ClusteringFields {
+			if f.CompareFunction != "delta" || f.FieldWeight != wantWeights[j] {
+				t.Errorf("KModes.ExportPMML() clustering field %d = %+v, want weight %v", j, f, wantWeights[j])
+			}
+		}
+
+		// Dropped cluster is left out.
+		if doc.Model.NumberOfClusters != 4 || len(centers) != 4 {
+			t.Fatalf("KModes.ExportPMML() has %d clusters, want 4", len(centers))
+		}
+		for i, c := range doc.Model.Clusters {
+			if c.ID != strconv.Itoa(i) || c.Size != km.LabelsCounter[i] || !reflect.DeepEqual(centers[i], km.ClusterCentroids.RawRowView(i)) {
+				t.Errorf("KModes.ExportPMML() cluster %d = %+v, centroid %v", i, c, km.ClusterCentroids.RawRowView(i))
+			}
+		}
+
+		labels, _ := km.Predict(X)
+		for i := 0; i < 200; i++ {
+			if got := scorePMML(doc, centers, X.RawRowView(i)); got != strconv.Itoa(int(labels.At(i, 0))) {
+				t.Errorf("PMML scoring of row %d = %s, Predict = %v", i, got, labels.At(i, 0))
+			}
+		}
+	}
+}
+
+func TestKPrototypes_ExportPMML(t *testing.T) {
+	X := NewDenseMatrix(6, 3, []float64{
+		10, 1, 5,
+		20, 1, 5,
+		30, 1, 5,
+		70, 2, 6,
+		80, 2, 6,
+		100, 2, 6,
+	})
+	kp := NewKPrototypes(WeightedHammingDistance, InitCao, []int{1, 2}, 2, 1, 10, [][]float64{{1, 3}}, 0.5, "")
+	kp.Seed = 1
+	if err := kp.FitModel(NewDenseMatrix(6, 3, append([]float64(nil), X.RawMatrix().Data...))); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := kp.ExportPMML(&buf, nil); err != nil {
+		t.Fatalf("KPrototypes.ExportPMML() error = %v", err)
+	}
+	doc, centers := parsePMML(t, buf.Bytes())
+
+	if got := doc.Model.ComparisonMeasure.Metric.XMLName.Local; got != "squaredEuclidean" {
+		t.Errorf("KPrototypes.ExportPMML() metric = %v", got)
+	}
+	wantFields := []pmmlClusteringField{
+		{Field: "x0", CompareFunction: "absDiff", FieldWeight: 1.0 / (100 * 100)},
+		{Field: "x1", CompareFunction: "delta", FieldWeight: 0.5},
+		{Field: "x2", CompareFunction: "delta", FieldWeight: 1.5},
+	}
+	if !reflect.DeepEqual(doc.Model.ClusteringFields, wantFields) {
+		t.Errorf("KPrototypes.ExportPMML() clustering fields = %+v, want %+v", doc.Model.ClusteringFields, wantFields)
+	}
+	if doc.DataDictionary.Fields[0].Optype != "continuous" || doc.DataDictionary.Fields[2].Optype != "categorical" {
+		t.Errorf("KPrototypes.ExportPMML() data dictionary = %+v", doc.DataDictionary)
+	}
+	for i, center := range centers {
+		want := []float64{kp.ClusterCentroidsNum.At(i, 0) * 100, kp.ClusterCentroidsCat.At(i, 0), kp.ClusterCentroidsCat.At(i, 1)}
+		if math.Abs(center[0]-want[0]) > 1e-9 || center[1] != want[1] || center[2] != want[2] {
+			t.Errorf("KPrototypes.ExportPMML() center %d = %v, want %v", i, center, want)
+		}
+	}
+	for i := 0; i < 6; i++ {
+		if got := scorePMML(doc, centers, X.RawRowView(i)); got != strconv.Itoa(int(kp.Labels.At(i, 0))) {
+			t.Errorf("PMML scoring of row %d = %s, label %v", i, got, kp.Labels.At(i, 0))
+		}
+	}
+}
+
+func TestExportPMMLErrors(t *testing.T) {
+	X := randomCategorical(50, 3, 3, 1)
+	km := NewKModes(HammingDistance, InitCao, 2, 1, 20, [][]float64{{1, 1, 1}}, "")
+	var buf bytes.Buffer
+	if err := km.ExportPMML(&buf, nil); err == nil {
+		t.Errorf("KModes.ExportPMML() of not fitted model did not fail")
+	}
+	km.Seed = 1
+	km.FitModel(X)
+	if err := km.ExportPMML(&buf, []string{"a"}); err == nil {
+		t.Errorf("KModes.ExportPMML() with wrong field names did not fail")
+	}
+	km.DistanceFunc = EuclideanDistance
+	if err := km.ExportPMML(&buf, nil); err == nil {
+		t.Errorf("KModes.ExportPMML() with Euclidean distance did not fail")
+	}
+	km.DistanceFunc = WeightedHammingDistance
+	km.WeightVectors = [][]float64{{1}}
+	if err := km.ExportPMML(&buf, nil); err == nil {
+		t.Errorf("KModes.ExportPMML() with wrong weights did not fail")
+	}
+
+	kp := NewKPrototypes(HammingDistance, InitCao, []int{0, 1}, 2, 1, 20, [][]float64{{1, 1}}, 1, "")
+	if err := kp.ExportPMML(&buf, nil); err == nil {
+		t.Errorf("KPrototypes.ExportPMML() of not fitted model did not fail")
+	}
+	kp.Seed = 1
+	if err := kp.FitModel(NewDenseMatrix(50, 3, append([]float64(nil), X.RawMatrix().Data...))); err != nil {
+		t.Fatal(err)
+	}
+	if err := kp.ExportPMML(&buf, []string{"a", "b"}); err == nil {
+		t.Errorf("KPrototypes.ExportPMML() with wrong field names did not fail")
+	}
+	kp.NumericScales = nil
+	if err := kp.ExportPMML(&buf, nil); err == nil {
+		t.Errorf("KPrototypes.ExportPMML() without numeric scales did not fail")
+	}
+}
@@ -0,0 +1,336 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/mathext"
+)
+
+// Profile describes clusters of a fitted model for reporting. Every cluster
+// is compared with the rest of the data: categorical attributes by
+// chi-square test of the cluster/rest contingency table, numerical ones by
+// one-way ANOVA of the two groups.
+type Profile struct {
+	Rows     int              // number of profiled rows
+	Weight   float64          // sum of sample weights of profiled rows, equal to Rows if rows are not weighted
+	Clusters []ClusterProfile // non-empty clusters ordered by label
+}
+
+// ClusterProfile describes a single cluster.
+type ClusterProfile struct {
+	Label       int
+	Size        int
+	Weight      float64              // sum of sample weights of members, equal to Size if rows are not weighted
+	Categorical []CategoricalProfile // categorical attributes in column order
+	Numeric     []NumericProfile     // numerical attributes in column order
+	Separating  []int                // columns ordered from the one which separates the cluster from the rest best
+}
+
+// CategoricalProfile describes the distribution of a categorical attribute in
+// a cluster.
+type CategoricalProfile struct {
+	Attribute int            // column of the data
+	Values    []ValueProfile // values present in the cluster ordered by decreasing count
+	ChiSquare float64        // chi-square statistic of the cluster/rest contingency table
+	PValue    float64        // p-value of ChiSquare
+	Effect    float64        // Cramér's V, from 0 (same distribution as the rest) to 1
+}
+
+// ValueProfile describes the frequency of a value of a categorical attribute
+// in a cluster.
+type ValueProfile struct {
+	Value float64
+	Count float64
+	Share float64 // fraction of cluster members (by weight) with the value
+	Lift  float64 // Share divided by the fraction of all rows (by weight) with the value
+}
+
+// NumericProfile describes the distribution of a numerical attribute in a
+// cluster.
+type NumericProfile struct {
+	Attribute                  int // column of the data
+	Mean, StdDev               float64
+	Min, Q25, Median, Q75, Max float64
+	F                          float64 // ANOVA F statistic of the cluster and the rest
+	PValue                     float64 // p-value of F
+	Effect                     float64 // correlation ratio, from 0 (same mean as the rest) to 1
+}
+
+// NewProfile profiles clustering of X described by labels. Columns listed in
+// categorical are treated as categorical attributes, the rest as numerical
+// ones. Rows with negative labels (noise) are left out.
+func NewProfile(X *DenseMatrix, categorical []int, labels *DenseVector, clusters int) (*Profile, error) {
+	xRows, xCols := X.Dims()
+	if labels.Len() != xRows {
+		return nil, errors.New("profile: labels length does not match data")
+	}
+	numerical, err := numericalColumns(categorical, xCols)
+	if err != nil {
+		return nil, fmt.Errorf("profile: %v", err)
+	}
+
+	sizes := make([]int, clusters)
+	frequencies := make([][]map[float64]float64, clusters)
+	for c := range frequencies {
+		frequencies[c] = make([]map[float64]float64, len(categorical))
+		for j := range frequencies[c] {
+			frequencies[c][j] = make(map[float64]float64)
+		}
+	}
+	for i := 0; i < xRows; i++ {
+		l := int(labels.At(i, 0))
+		if l < 0 {
+			continue
+		}
+		if l >= clusters {
+			return nil, fmt.Errorf("profile: label %d of row %d out of range", l, i)
+		}
+		sizes[l]++
+		for j, col := range categorical {
+			frequencies[l][j][X.At(i, col)]++
+		}
+	}
+	return newProfile(sizes, nil, categorical, frequencies, numerical, numericValues(X, numerical, labels, clusters)), nil
+}
+
+// Profile describes clusters of the fitted model using FrequencyTable, which
+// is weighted if the model was fitted with FitModelWeighted.
+func (km *KModes) Profile() (*Profile, error) {
+	if !km.IsFitted {
+		return nil, errors.New("kmodes: cannot profile clusters, model is not fitted yet")
+	}
+	_, xCols := km.ClusterCentroids.Dims()
+	categorical := make([]int, xCols)
+	for j := range categorical {
+		categorical[j] = j
+	}
+	return newProfile(km.LabelsCounter, km.ClusterWeights, categorical, km.FrequencyTable, nil, nil), nil
+}
+
+// Profile describes clusters of the fitted model. Categorical attributes are
+// taken from FrequencyTable, numerical ones from X, which must be the
+// training data in original units. Numerical attributes are not weighted even
+// if the model was fitted with FitModelWeighted. Models without training
+// labels, e.g. saved by gocluster, cannot be profiled.
+func (km *KPrototypes) Profile(X *DenseMatrix) (*Profile, error) {
+	if !km.IsFitted {
+		return nil, errors.New("kmodes: cannot profile clusters, model is not fitted yet")
+	}
+	if km.Labels == nil {
+		return nil, errors.New("kmodes: cannot profile clusters, model has no labels")
+	}
+	xRows, xCols := X.Dims()
+	if xRows != km.Labels.Len() {
+		return nil, errors.New("kmodes: cannot profile clusters, data does not match training labels")
+	}
+	numerical, err := numericalColumns(km.CategoricalInd, xCols)
+	if err != nil {
+		return nil, fmt.Errorf("kmodes: cannot profile clusters: %v", err)
+	}
+	values := numericValues(X, numerical, km.Labels, km.ClustersNumber)
+	return newProfile(km.LabelsCounter, km.ClusterWeights, km.CategoricalInd, km.FrequencyTable, numerical, values), nil
+}
+
+// numericalColumns returns columns which are not listed in categorical.
+func numericalColumns(categorical []int, cols int) ([]int, error) {
+	isCat := make([]bool, cols)
+	for _, col := range categorical {
+		if col < 0 || col >= cols {
+			return nil, fmt.Errorf("categorical column %d out of range", col)
+		}
+		isCat[col] = true
+	}
+	var numerical []int
+	for j, cat := range isCat {
+		if !cat {
+			numerical = append(numerical, j)
+		}
+	}
+	return numerical, nil
+}
+
+// numericValues collects values of numerical columns of X per cluster and
+// attribute.
+func numericValues(X *DenseMatrix, numerical []int, labels *DenseVector, clusters int) [][][]float64 {
+	values := make([][][]float64, clusters)
+	for c := range values {
+		values[c] = make([][]float64, len(numerical))
+	}
+	xRows, _ := X.Dims()
+	for i := 0; i < xRows; i++ {
+		l := int(labels.At(i, 0))
+		if l < 0 || l >= clusters {
+			continue
+		}
+		for j, col := range numerical {
+			values[l][j] = append(values[l][j], X.At(i, col))
+		}
+	}
+	return values
+}
+
+// newProfile builds the profile from per cluster counts of rows and
+// frequencies of categorical values. weights are sums of sample weights per
+// cluster which match frequencies, nil if rows are not weighted.
+func newProfile(sizes []int, weights []float64, categorical []int, frequencies [][]map[float64]float64, numerical []int, values [][][]float64) *Profile {
+	if weights == nil {
+		weights = make([]float64, len(sizes))
+		for c, size := range sizes {
+			weights[c] = float64(size)
+		}
+	}
+	p := &Profile{}
+	for c, size := range sizes {
+		p.Rows += size
+		p.Weight += weights[c]
+	}
+
+	// Distributions over all clusters.
+	total := make([]map[float64]float64, len(categorical))
+	for j := range total {
+		total[j] = make(map[float64]float64)
+		for c := range frequencies {
+			for v, count := range frequencies[c][j] {
+				total[j][v] += count
+			}
+		}
+	}
+	all := make([][]float64, len(numerical))
+	for j := range all {
+		for c := range values {
+			all[j] = append(all[j], values[c][j]...)
+		}
+	}
+
+	for c, size := range sizes {
+		if size == 0 {
+			continue
+		}
+		cp := ClusterProfile{Label: c, Size: size, Weight: weights[c]}
+		effects := make(map[int]float64)
+		for j, col := range categorical {
+			prof := categoricalProfile(col, weights[c], p.Weight, frequencies[c][j], total[j])
+			cp.Categorical = append(cp.Categorical, prof)
+			cp.Separating = append(cp.Separating, col)
+			effects[col] = prof.Effect
+		}
+		for j, col := range numerical {
+			prof := numericProfile(col, values[c][j], all[j])
+			cp.Numeric = append(cp.Numeric, prof)
+			cp.Separating = append(cp.Separating, col)
+			effects[col] = prof.Effect
+		}
+		sort.SliceStable(cp.Separating, func(a, b int) bool {
+			return effects[cp.Separating[a]] > effects[cp.Separating[b]]
+		})
+		p.Clusters = append(p.Clusters, cp)
+	}
+	return p
+}
+
+// categoricalProfile compares frequencies of values of an attribute in a
+// cluster of the given size with frequencies in all rows, sizes are sums of
+// weights of rows.
+func categoricalProfile(col int, size, rows float64, frequency, total map[float64]float64) CategoricalProfile {
+	prof := CategoricalProfile{Attribute: col, PValue: 1}
+	for v, count := range frequency {
+		if count > 0 {
+			prof.Values = append(prof.Values, ValueProfile{
+				Value: v,
+				Count: count,
+				Share: count / size,
+				Lift:  count / size / (total[v] / rows),
+			})
+		}
+	}
+	sort.Slice(prof.Values, func(a, b int) bool {
+		if prof.Values[a].Count != prof.Values[b].Count {
+			return prof.Values[a].Count > prof.Values[b].Count
+		}
+		return prof.Values[a].Value < prof.Values[b].Value
+	})
+
+	rest := rows - size
+	if rest <= 0 {
+		return prof
+	}
+	// Values are summed in order to make results reproducible.
+	levels := make([]float64, 0, len(total))
+	for v, all := range total {
+		if all > 0 {
+			levels = append(levels, v)
+		}
+	}
+	sort.Float64s(levels)
+	for _, v := range levels {
+		all := total[v]
+		in, out := frequency[v], all-frequency[v]
+		expIn := size * all / rows
+		expOut := rest * all / rows
+		prof.ChiSquare += (in-expIn)*(in-expIn)/expIn + (out-expOut)*(out-expOut)/expOut
+	}
+	if len(levels) < 2 {
+		return prof
+	}
+	prof.PValue = mathext.GammaIncRegComp(float64(len(levels)-1)/2, prof.ChiSquare/2)
+	// The contingency table has two rows, so min(rows, columns) - 1 = 1.
+	prof.Effect = math.Sqrt(prof.ChiSquare / rows)
+	return prof
+}
+
+// numericProfile describes values of an attribute in a cluster and compares
+// them with all values.
+func numericProfile(col int, values, all []float64) NumericProfile {
+	prof := NumericProfile{Attribute: col, PValue: 1}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	prof.Min, prof.Max = sorted[0], sorted[len(sorted)-1]
+	prof.Q25, prof.Median, prof.Q75 = quantile(sorted, 0.25), quantile(sorted, 0.5), quantile(sorted, 0.75)
+	prof.Mean, prof.StdDev = meanStdDev(values)
+
+	n, nAll := float64(len(values)), float64(len(all))
+	if nAll-n == 0 {
+		return prof
+	}
+	meanAll, _ := meanStdDev(all)
+	meanRest := (meanAll*nAll - prof.Mean*n) / (nAll - n)
+	var total float64
+	for _, v := range all {
+		total += (v - meanAll) * (v - meanAll)
+	}
+	between := n*(prof.Mean-meanAll)*(prof.Mean-meanAll) + (nAll-n)*(meanRest-meanAll)*(meanRest-meanAll)
+	within := total - between
+	switch {
+	case total == 0 || nAll < 3:
+		return prof
+	case within <= 0:
+		prof.F, prof.PValue = math.Inf(1), 0
+	default:
+		prof.F = between / (within / (nAll - 2))
+		// Survival function of the F(1, n-2) distribution.
+		prof.PValue = mathext.RegIncBeta((nAll-2)/2, 0.5, (nAll-2)/(nAll-2+prof.F))
+	}
+	prof.Effect = math.Sqrt(math.Min(between/total, 1))
+	return prof
+}
+
+// meanStdDev returns the mean and the sample standard deviation of values,
+// the deviation is 0 for a single value.
+func meanStdDev(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)-1))
+}
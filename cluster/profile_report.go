@@ -0,0 +1,172 @@
+package cluster
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// reportValues is the largest number of values of a categorical attribute
+// listed per cluster by renderers, the rest is summarized in one line.
+const reportValues = 10
+
+// WriteText writes the profile as plain text tables. names are attribute
+// names in column order, if nil x0, x1, ... are used. values optionally map
+// codes of categorical attributes to readable values, per column.
+func (p *Profile) WriteText(w io.Writer, names []string, values []map[float64]string) error {
+	tables, err := p.tables(names, values)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for c, cp := range p.Clusters {
+		if c > 0 {
+			fmt.Fprintln(&buf)
+		}
+		fmt.Fprintf(&buf, "Cluster %d: %s\n", cp.Label, p.sizeText(cp))
+		for _, t := range tables[c] {
+			fmt.Fprintf(&buf, "\n%s\n", t.title)
+			tw := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+			for _, row := range t.rows {
+				fmt.Fprintln(tw, strings.Join(row, "\t"))
+			}
+			tw.Flush()
+		}
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// WriteMarkdown writes the profile as Markdown with a section and tables per
+// cluster. names and values are used as in WriteText.
+func (p *Profile) WriteMarkdown(w io.Writer, names []string, values []map[float64]string) error {
+	tables, err := p.tables(names, values)
+	if err != nil {
+		return err
+	}
+	escape := strings.NewReplacer("|", `\|`)
+	var buf bytes.Buffer
+	for c, cp := range p.Clusters {
+		if c > 0 {
+			fmt.Fprintln(&buf)
+		}
+		fmt.Fprintf(&buf, "## Cluster %d\n\n%s\n", cp.Label, p.sizeText(cp))
+		for _, t := range tables[c] {
+			fmt.Fprintf(&buf, "\n### %s\n\n", t.title)
+			for i, row := range t.rows {
+				cells := make([]string, len(row))
+				for j, cell := range row {
+					cells[j] = escape.Replace(cell)
+				}
+				fmt.Fprintf(&buf, "| %s |\n", strings.Join(cells, " | "))
+				if i == 0 {
+					fmt.Fprintf(&buf, "|%s\n", strings.Repeat(" --- |", len(row)))
+				}
+			}
+		}
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// reportTable is a titled table, the first row is the header.
+type reportTable struct {
+	title string
+	rows  [][]string
+}
+
+func (p *Profile) sizeText(cp ClusterProfile) string {
+	return fmt.Sprintf("%d rows (%.1f%%)", cp.Size, 100*float64(cp.Size)/float64(p.Rows))
+}
+
+// tables prepares tables rendered for every cluster.
+func (p *Profile) tables(names []string, values []map[float64]string) ([][]reportTable, error) {
+	var cols int
+	for _, cp := range p.Clusters {
+		for _, prof := range cp.Categorical {
+			if prof.Attribute >= cols {
+				cols = prof.Attribute + 1
+			}
+		}
+		for _, prof := range cp.Numeric {
+			if prof.Attribute >= cols {
+				cols = prof.Attribute + 1
+			}
+		}
+	}
+	names, err := attributeNames(names, cols)
+	if err != nil {
+		return nil, fmt.Errorf("profile: %v", err)
+	}
+	value := func(col int, v float64) string {
+		if col < len(values) {
+			if name, ok := values[col][v]; ok {
+				return name
+			}
+		}
+		return formatStat(v)
+	}
+
+	tables := make([][]reportTable, len(p.Clusters))
+	for c, cp := range p.Clusters {
+		separation := reportTable{title: "Separating attributes", rows: [][]string{{"attribute", "test", "statistic", "p-value", "effect"}}}
+		byColumn := make(map[int][]string)
+		for _, prof := range cp.Categorical {
+			byColumn[prof.Attribute] = []string{names[prof.Attribute], "chi-square", formatStat(prof.ChiSquare), formatPValue(prof.PValue), formatStat(prof.Effect)}
+		}
+		for _, prof := range cp.Numeric {
+			byColumn[prof.Attribute] = []string{names[prof.Attribute], "ANOVA", formatStat(prof.F), formatPValue(prof.PValue), formatStat(prof.Effect)}
+		}
+		for _, col := range cp.Separating {
+			separation.rows = append(separation.rows, byColumn[col])
+		}
+		tables[c] = append(tables[c], separation)
+
+		if len(cp.Categorical) > 0 {
+			t := reportTable{title: "Categorical attributes", rows: [][]string{{"attribute", "value", "count", "share", "lift"}}}
+			for _, prof := range cp.Categorical {
+				for i, v := range prof.Values {
+					if i == reportValues {
+						t.rows = append(t.rows, []string{names[prof.Attribute], fmt.Sprintf("(%d more)", len(prof.Values)-i), "", "", ""})
+						break
+					}
+					t.rows = append(t.rows, []string{
+						names[prof.Attribute],
+						value(prof.Attribute, v.Value),
+						formatStat(v.Count),
+						fmt.Sprintf("%.1f%%", 100*v.Share),
+						fmt.Sprintf("%.2f", v.Lift),
+					})
+				}
+			}
+			tables[c] = append(tables[c], t)
+		}
+
+		if len(cp.Numeric) > 0 {
+			t := reportTable{title: "Numerical attributes", rows: [][]string{{"attribute", "mean", "std. dev.", "min", "q25", "median", "q75", "max"}}}
+			for _, prof := range cp.Numeric {
+				row := []string{names[prof.Attribute]}
+				for _, v := range []float64{prof.Mean, prof.StdDev, prof.Min, prof.Q25, prof.Median, prof.Q75, prof.Max} {
+					row = append(row, formatStat(v))
+				}
+				t.rows = append(t.rows, row)
+			}
+			tables[c] = append(tables[c], t)
+		}
+	}
+	return tables, nil
+}
+
+func formatStat(v float64) string {
+	return strconv.FormatFloat(v, 'g', 4, 64)
+}
+
+func formatPValue(v float64) string {
+	if v < 1e-4 {
+		return "<0.0001"
+	}
+	return strconv.FormatFloat(v, 'f', 4, 64)
+}
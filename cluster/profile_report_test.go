@@ -0,0 +1,105 @@
+package cluster
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestProfile_WriteText(t *testing.T) {
+	labels := NewDenseVector(6, []float64{0, 0, 0, 1, 1, 1})
+	p, _ := NewProfile(profileTestData(), []int{0}, labels, 2)
+	var buf bytes.Buffer
+	if err := p.WriteText(&buf, []string{"color", "size"}, []map[float64]string{{1: "red", 2: "blue"}}); err != nil {
+		t.Fatal(err)
+	}
+	want := `Cluster 0: 3 rows (50.0%)
+
+Separating attributes
+attribute  test        statistic  p-value  effect
+size       ANOVA       36.1       0.0039   0.9488
+color      chi-square  3          0.0833   0.7071
+
+Categorical attributes
+attribute  value  count  share   lift
+color      red    3      100.0%  1.50
+
+Numerical attributes
+attribute  mean  std. dev.  min  q25  median  q75  max
+size       20    10         10   15   20      25   30
+
+Cluster 1: 3 rows (50.0%)
+
+Separating attributes
+attribute  test        statistic  p-value  effect
+size       ANOVA       36.1       0.0039   0.9488
+color      chi-square  3          0.0833   0.7071
+
+Categorical attributes
+attribute  value  count  share  lift
+color      blue   2      66.7%  2.00
+color      red    1      33.3%  0.50
+
+Numerical attributes
+attribute  mean   std. dev.  min  q25  median  q75  max
+size       83.33  15.28      70   75   80      90   100
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteText() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestProfile_WriteMarkdown(t *testing.T) {
+	labels := NewDenseVector(6, []float64{0, 0, 0, 0, 0, 1})
+	p, _ := NewProfile(profileTestData(), []int{0, 1}, labels, 2)
+	var buf bytes.Buffer
+	if err := p.WriteMarkdown(&buf, []string{"a|b", "size"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := `## Cluster 0
+
+5 rows (83.3%)
+
+### Separating attributes
+
+| attribute | test | statistic | p-value | effect |
+| --- | --- | --- | --- | --- |
+| size | chi-square | 6 | 0.3062 | 1 |
+| a\|b | chi-square | 0.6 | 0.4386 | 0.3162 |
+
+### Categorical attributes
+
+| attribute | value | count | share | lift |
+| --- | --- | --- | --- | --- |
+| a\|b | 1 | 3 | 60.0% | 0.90 |
+| a\|b | 2 | 2 | 40.0% | 1.20 |
+| size | 10 | 1 | 20.0% | 1.20 |
+| size | 20 | 1 | 20.0% | 1.20 |
+| size | 30 | 1 | 20.0% | 1.20 |
+| size | 70 | 1 | 20.0% | 1.20 |
+| size | 80 | 1 | 20.0% | 1.20 |
+
+## Cluster 1
+
+1 rows (16.7%)
+
+### Separating attributes
+
+| attribute | test | statistic | p-value | effect |
+| --- | --- | --- | --- | --- |
+| size | chi-square | 6 | 0.3062 | 1 |
+| a\|b | chi-square | 0.6 | 0.4386 | 0.3162 |
+
+### Categorical attributes
+
+| attribute | value | count | share | lift |
+| --- | --- | --- | --- | --- |
+| a\|b | 1 | 1 | 100.0% | 1.50 |
+| size | 100 | 1 | 100.0% | 6.00 |
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteMarkdown() =\n%s\nwant\n%s", got, want)
+	}
+	if err := p.WriteMarkdown(&buf, []string{"a"}, nil); err == nil {
+		t.Error("WriteMarkdown() with too few names, want error")
+	}
+}
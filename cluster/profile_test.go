@@ -0,0 +1,132 @@
+package cluster
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func profileTestData() *DenseMatrix {
+	return NewDenseMatrix(6, 2, []float64{
+		1, 10,
+		1, 20,
+		1, 30,
+		2, 70,
+		2, 80,
+		1, 100,
+	})
+}
+
+func TestNewProfile(t *testing.T) {
+	labels := NewDenseVector(6, []float64{0, 0, 0, 1, 1, 1})
+	p, err := NewProfile(profileTestData(), []int{0}, labels, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Rows != 6 || len(p.Clusters) != 2 {
+		t.Fatalf("NewProfile() = %d rows, %d clusters, want 6 rows, 2 clusters", p.Rows, len(p.Clusters))
+	}
+
+	cp := p.Clusters[0]
+	cat := cp.Categorical[0]
+	if want := []ValueProfile{{Value: 1, Count: 3, Share: 1, Lift: 1.5}}; !reflect.DeepEqual(cat.Values, want) {
+		t.Errorf("cluster 0 values = %v, want %v", cat.Values, want)
+	}
+	num := cp.Numeric[0]
+	got := []float64{
+		cat.ChiSquare, cat.PValue, cat.Effect,
+		num.Mean, num.StdDev, num.Min, num.Q25, num.Median, num.Q75, num.Max,
+		num.F, num.PValue, num.Effect,
+	}
+	want := []float64{
+		3, 0.0832645166635504, math.Sqrt(0.5),
+		20, 10, 10, 15, 20, 25, 30,
+		36.1, 0.0038628480444041, 0.9488147219339524,
+	}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-9 {
+			t.Errorf("cluster 0 statistic %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+	if want := []int{1, 0}; !reflect.DeepEqual(cp.Separating, want) {
+		t.Errorf("cluster 0 separating = %v, want %v", cp.Separating, want)
+	}
+
+	values := p.Clusters[1].Categorical[0].Values
+	if len(values) != 2 || values[0].Value != 2 || values[0].Lift != 2 || values[1].Lift != 0.5 {
+		t.Errorf("cluster 1 values = %v", values)
+	}
+}
+
+func TestNewProfile_Errors(t *testing.T) {
+	X := profileTestData()
+	tests := []struct {
+		categorical []int
+		labels      []float64
+	}{
+		{[]int{2}, []float64{0, 0, 0, 1, 1, 1}},
+		{[]int{0}, []float64{0, 0, 0, 1, 1, 3}},
+		{[]int{0}, []float64{0, 0, 0, 1, 1}},
+	}
+	for i, tt := range tests {
+		if _, err := NewProfile(X, tt.categorical, NewDenseVector(len(tt.labels), tt.labels), 3); err == nil {
+			t.Errorf("%d. NewProfile() error = nil, want error", i)
+		}
+	}
+}
+
+func TestNewProfile_Noise(t *testing.T) {
+	labels := NewDenseVector(6, []float64{0, 0, 0, 0, -1, -1})
+	p, err := NewProfile(profileTestData(), []int{0}, labels, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cat := p.Clusters[0].Categorical[0]
+	if p.Rows != 4 || cat.PValue != 1 || cat.Effect != 0 || p.Clusters[0].Numeric[0].F != 0 {
+		t.Errorf("single cluster profile = %+v", p)
+	}
+}
+
+func TestKModes_Profile(t *testing.T) {
+	X := randomCategorical(60, 4, 3, 2)
+	km := NewKModes(HammingDistance, InitHuang, 3, 1, 20, [][]float64{{1}}, "")
+	km.Seed = 1
+	if _, err := km.Profile(); err == nil {
+		t.Error("Profile() of not fitted model, want error")
+	}
+	if err := km.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+	got, err := km.Profile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := NewProfile(X, []int{0, 1, 2, 3}, km.Labels, 3)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("KModes.Profile() = %+v, want %+v", got, want)
+	}
+}
+
+func TestKPrototypes_Profile(t *testing.T) {
+	X := profileTestData()
+	kp := NewKPrototypes(HammingDistance, InitCao, []int{0}, 2, 1, 10, [][]float64{{1}}, 0.5, "")
+	kp.Seed = 1
+	if err := kp.FitModel(NewDenseMatrix(6, 2, append([]float64(nil), X.RawMatrix().Data...))); err != nil {
+		t.Fatal(err)
+	}
+	got, err := kp.Profile(X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := NewProfile(X, []int{0}, kp.Labels, 2)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("KPrototypes.Profile() = %+v, want %+v", got, want)
+	}
+	if _, err := kp.Profile(NewDenseMatrix(2, 2, nil)); err == nil {
+		t.Error("Profile() of other data, want error")
+	}
+	kp.Labels = nil
+	if _, err := kp.Profile(X); err == nil {
+		t.Error("Profile() of model without labels, want error")
+	}
+}
@@ -0,0 +1,188 @@
+package cluster
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+)
+
+// checkSampleWeights validates weights of rows passed to FitModelWeighted.
+func checkSampleWeights(weights []float64, rows int) error {
+	if len(weights) != rows {
+		return fmt.Errorf("got %d sample weights for %d rows", len(weights), rows)
+	}
+	for i, w := range weights {
+		if !(w > 0) || math.IsInf(w, 1) {
+			return fmt.Errorf("sample weight %v of row %d is not a positive number", w, i)
+		}
+	}
+	return nil
+}
+
+// sampleWeight returns the weight of row i, rows have weight 1 if weights is
+// nil.
+func sampleWeight(weights []float64, i int) float64 {
+	if weights == nil {
+		return 1
+	}
+	return weights[i]
+}
+
+// totalSampleWeight returns the sum of weights of rows, which is the number
+// of rows if weights is nil.
+func totalSampleWeight(weights []float64, rows int) float64 {
+	if weights == nil {
+		return float64(rows)
+	}
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	return total
+}
+
+// compressRows collapses identical rows of X into unique rows in the order of
+// their first occurrence. It returns unique rows, their weights, which are
+// sums of weights of collapsed rows, and the unique row of every row of X.
+func compressRows(X Matrix, weights []float64) (*DenseMatrix, []float64, []int) {
+	xRows, xCols := X.Dims()
+	index := make(map[string]int)
+	rows := make([]int, xRows)
+	var data, uniqueWeights []float64
+	row := make([]float64, xCols)
+	key := make([]byte, 8*xCols)
+	for i := 0; i < xRows; i++ {
+		row = X.RowTo(row, i)
+		for j, v := range row {
+			binary.LittleEndian.PutUint64(key[8*j:], math.Float64bits(v))
+		}
+		u, ok := index[string(key)]
+		if !ok {
+			u = len(uniqueWeights)
+			index[string(key)] = u
+			data = append(data, row...)
+			uniqueWeights = append(uniqueWeights, 0)
+		}
+		uniqueWeights[u] += sampleWeight(weights, i)
+		rows[i] = u
+	}
+	return NewDenseMatrix(len(uniqueWeights), xCols, data), uniqueWeights, rows
+}
+
+// compression keeps track of original rows during a fit on compressed rows,
+// so that empty clusters are refilled with single copies of rows exactly as
+// without compression.
+type compression struct {
+	rows      []int               // unique row of every original row
+	weights   []float64           // weights of original rows, nil if rows are not weighted
+	counts    []int               // numbers of original rows of unique rows
+	distances []float64           // distances of unique rows to their centroids
+	moved     map[int][]movedCopy // copies of unique rows moved to empty clusters
+	labels    *DenseVector        // labels of original rows while empty clusters are refilled
+	costs     []float64           // costs of original rows while empty clusters are refilled
+}
+
+// movedCopy is an original row which was moved to an empty cluster apart from
+// other copies of its unique row.
+type movedCopy struct {
+	row, cluster int
+}
+
+func newCompression(rows []int, weights []float64, uniqueRows int) *compression {
+	c := &compression{
+		rows:      rows,
+		weights:   weights,
+		counts:    make([]int, uniqueRows),
+		distances: make([]float64, uniqueRows),
+		moved:     make(map[int][]movedCopy),
+	}
+	for _, u := range rows {
+		c.counts[u]++
+	}
+	return c
+}
+
+// count returns the number of original rows of unique row u, it is 1 if rows
+// are not compressed.
+func (c *compression) count(u int) int {
+	if c == nil {
+		return 1
+	}
+	return c.counts[u]
+}
+
+// share returns the number and the weight of copies of unique row u which
+// follow its label, i.e. were not moved to empty clusters. weight is the
+// weight of all copies.
+func (c *compression) share(u int, weight float64) (int, float64) {
+	if c == nil {
+		return 1, weight
+	}
+	n := c.counts[u]
+	for _, m := range c.moved[u] {
+		n--
+		weight -= sampleWeight(c.weights, m.row)
+	}
+	return n, weight
+}
+
+// setDistance records the distance of unique row u to its centroid.
+func (c *compression) setDistance(u int, d float64) {
+	if c != nil {
+		c.distances[u] = d
+	}
+}
+
+// expand sets labels and costs of original rows from labels of unique rows,
+// costs are weighted distances as in a fit without compression.
+func (c *compression) expand(labels *DenseVector) {
+	c.labels = NewDenseVector(len(c.rows), nil)
+	c.costs = make([]float64, len(c.rows))
+	for r, u := range c.rows {
+		c.labels.SetVec(r, labels.At(u, 0))
+		c.costs[r] = sampleWeight(c.weights, r) * c.distances[u]
+	}
+	for _, copies := range c.moved {
+		for _, m := range copies {
+			c.labels.SetVec(m.row, float64(m.cluster))
+			c.costs[m.row] = 0
+		}
+	}
+}
+
+// modelInitialization returns init which uses the raw distance of the model
+// and takes weights into account. Initializations of this package compute
+// distances with rawDist, which is bound to attribute weights of the model.
+// Only InitCao uses densities of rows, other initializations ignore weights.
+func modelInitialization(init InitializationFunction, rawDist RawDistanceFunction, weights []float64) InitializationFunction {
+	switch reflect.ValueOf(init).Pointer() {
+	case reflect.ValueOf(InitCao).Pointer():
+		return func(X Matrix, clustersNumber int, distFunc DistanceFunction, rnd *rand.Rand) (*DenseMatrix, error) {
+			return initCao(X, clustersNumber, rawDist, weights)
+		}
+	case reflect.ValueOf(InitHuang).Pointer():
+		return func(X Matrix, clustersNumber int, distFunc DistanceFunction, rnd *rand.Rand) (*DenseMatrix, error) {
+			return initHuang(X, clustersNumber, rawDist, rnd)
+		}
+	}
+	return init
+}
+
+// modelMixedInitialization returns init which uses the raw distance of the
+// model and takes weights into account like modelInitialization. Only
+// InitCaoMixed uses densities of rows, other initializations ignore weights.
+func modelMixedInitialization(init MixedInitializationFunction, rawDist RawDistanceFunction, weights []float64) MixedInitializationFunction {
+	switch reflect.ValueOf(init).Pointer() {
+	case reflect.ValueOf(InitCaoMixed).Pointer():
+		return func(xCat, xNum *DenseMatrix, clustersNumber int, gamma float64, distFunc DistanceFunction, rnd *rand.Rand) (*DenseMatrix, *DenseMatrix, error) {
+			return initCaoMixed(xCat, xNum, clustersNumber, gamma, rawDist, weights)
+		}
+	case reflect.ValueOf(InitPlusPlusMixed).Pointer():
+		return func(xCat, xNum *DenseMatrix, clustersNumber int, gamma float64, distFunc DistanceFunction, rnd *rand.Rand) (*DenseMatrix, *DenseMatrix, error) {
+			return initPlusPlusMixed(xCat, xNum, clustersNumber, gamma, rawDist, rnd)
+		}
+	}
+	return init
+}
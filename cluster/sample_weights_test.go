@@ -0,0 +1,308 @@
+package cluster
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// repeatRows returns X with row i repeated counts[i] times.
+func repeatRows(X *DenseMatrix, counts []int) *DenseMatrix {
+	_, xCols := X.Dims()
+	var data []float64
+	for i, c := range counts {
+		for k := 0; k < c; k++ {
+			data = append(data, X.RawRowView(i)...)
+		}
+	}
+	return NewDenseMatrix(len(data)/xCols, xCols, data)
+}
+
+func randomCounts(rows int, seed int64) ([]int, []float64) {
+	rnd := rand.New(rand.NewSource(seed))
+	counts := make([]int, rows)
+	weights := make([]float64, rows)
+	for i := range counts {
+		counts[i] = 1 + rnd.Intn(4)
+		weights[i] = float64(counts[i])
+	}
+	return counts, weights
+}
+
+func closeFloats(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9*math.Max(1, math.Abs(b[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCheckSampleWeights(t *testing.T) {
+	tests := []struct {
+		weights []float64
+		wantErr bool
+	}{
+		{[]float64{1, 0.5, 3}, false},
+		{[]float64{1, 2}, true},
+		{[]float64{1, 0, 3}, true},
+		{[]float64{1, -1, 3}, true},
+		{[]float64{1, math.NaN(), 3}, true},
+		{[]float64{1, math.Inf(1), 3}, true},
+	}
+	for i, tt := range tests {
+		if err := checkSampleWeights(tt.weights, 3); (err != nil) != tt.wantErr {
+			t.Errorf("%d. checkSampleWeights() error = %v, wantErr %v", i, err, tt.wantErr)
+		}
+	}
+}
+
+func TestWeightedQuantile(t *testing.T) {
+	sorted := []float64{1, 2, 5, 7}
+	weights := []float64{2, 1, 3, 1}
+	var repeated []float64
+	for i, v := range sorted {
+		for k := 0; k < int(weights[i]); k++ {
+			repeated = append(repeated, v)
+		}
+	}
+	for _, level := range []float64{0, 0.1, 0.25, 0.5, 0.66, 0.9, 1} {
+		if got, want := weightedQuantile(sorted, weights, level), quantile(repeated, level); math.Abs(got-want) > 1e-12 {
+			t.Errorf("weightedQuantile(%v) = %v, want %v", level, got, want)
+		}
+	}
+	if got := weightedQuantile([]float64{3}, []float64{0.5}, 0.5); got != 3 {
+		t.Errorf("weightedQuantile() of a light single value = %v, want 3", got)
+	}
+}
+
+func TestKModes_FitModelWeighted(t *testing.T) {
+	X := randomCategorical(80, 5, 3, 20)
+	counts, weights := randomCounts(80, 4)
+	newModel := func() *KModes {
+		km := NewKModes(HammingDistance, InitCao, 4, 1, 20, [][]float64{{1}}, "")
+		km.Seed = 1
+		km.EmptyClusterAction = EmptyClusterDrop
+		km.TieBreakPolicy = TieBreakGlobalFrequency
+		return km
+	}
+
+	weighted := newModel()
+	if err := weighted.FitModelWeighted(X, weights); err != nil {
+		t.Fatal(err)
+	}
+	expanded := newModel()
+	if err := expanded.FitModel(repeatRows(X, counts)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(weighted.ClusterCentroids.RawMatrix().Data, expanded.ClusterCentroids.RawMatrix().Data) {
+		t.Errorf("weighted centroids = %v, want %v", weighted.ClusterCentroids.RawMatrix().Data, expanded.ClusterCentroids.RawMatrix().Data)
+	}
+	if !reflect.DeepEqual(weighted.FrequencyTable, expanded.FrequencyTable) {
+		t.Errorf("weighted frequency table = %v, want %v", weighted.FrequencyTable, expanded.FrequencyTable)
+	}
+	if !closeFloats([]float64{weighted.Cost}, []float64{expanded.Cost}) || weighted.Iterations != expanded.Iterations {
+		t.Errorf("weighted cost = %v after %d iterations, want %v after %d", weighted.Cost, weighted.Iterations, expanded.Cost, expanded.Iterations)
+	}
+	for c := range expanded.LabelsCounter {
+		if weighted.ClusterWeights[c] != float64(expanded.LabelsCounter[c]) {
+			t.Errorf("cluster %d weight = %v, want %d", c, weighted.ClusterWeights[c], expanded.LabelsCounter[c])
+		}
+		if !closeFloats(weighted.DistanceQuantiles[c], expanded.DistanceQuantiles[c]) {
+			t.Errorf("cluster %d distance quantiles = %v, want %v", c, weighted.DistanceQuantiles[c], expanded.DistanceQuantiles[c])
+		}
+	}
+
+	got, err := weighted.Profile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := expanded.Profile()
+	if got.Weight != float64(want.Rows) || !reflect.DeepEqual(got.Clusters[0].Categorical, want.Clusters[0].Categorical) {
+		t.Errorf("weighted profile = %+v, want %+v", got.Clusters[0], want.Clusters[0])
+	}
+
+	if err := newModel().FitModelWeighted(X, weights[1:]); err == nil {
+		t.Error("FitModelWeighted() with too few weights, want error")
+	}
+}
+
+func TestKPrototypes_FitModelWeighted(t *testing.T) {
+	rnd := rand.New(rand.NewSource(5))
+	data := make([]float64, 60*4)
+	for i := 0; i < 60; i++ {
+		data[i*4], data[i*4+1] = float64(rnd.Intn(3)), float64(rnd.Intn(3))
+		data[i*4+2], data[i*4+3] = rnd.Float64()*10, rnd.Float64()
+	}
+	X := NewDenseMatrix(60, 4, data)
+	counts, weights := randomCounts(60, 6)
+	newModel := func() *KPrototypes {
+		kp := NewKPrototypes(HammingDistance, nil, []int{0, 1}, 3, 1, 20, [][]float64{{1}}, 0.5, "")
+		kp.MixedInitFunc = InitCaoMixed
+		kp.Seed = 1
+		kp.EmptyClusterAction = EmptyClusterDrop
+		return kp
+	}
+
+	weighted := newModel()
+	if err := weighted.FitModelWeighted(NewDenseMatrix(60, 4, append([]float64(nil), data...)), weights); err != nil {
+		t.Fatal(err)
+	}
+	expanded := newModel()
+	if err := expanded.FitModel(repeatRows(X, counts)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !closeFloats(weighted.ClusterCentroids.RawMatrix().Data, expanded.ClusterCentroids.RawMatrix().Data) {
+		t.Errorf("weighted centroids = %v, want %v", weighted.ClusterCentroids.RawMatrix().Data, expanded.ClusterCentroids.RawMatrix().Data)
+	}
+	if !reflect.DeepEqual(weighted.FrequencyTable, expanded.FrequencyTable) {
+		t.Errorf("weighted frequency table = %v, want %v", weighted.FrequencyTable, expanded.FrequencyTable)
+	}
+	if !closeFloats([]float64{weighted.Cost}, []float64{expanded.Cost}) || weighted.Iterations != expanded.Iterations {
+		t.Errorf("weighted cost = %v after %d iterations, want %v after %d", weighted.Cost, weighted.Iterations, expanded.Cost, expanded.Iterations)
+	}
+	for c := range expanded.LabelsCounter {
+		if weighted.ClusterWeights[c] != float64(expanded.LabelsCounter[c]) {
+			t.Errorf("cluster %d weight = %v, want %d", c, weighted.ClusterWeights[c], expanded.LabelsCounter[c])
+		}
+	}
+
+	weights[3] = -1
+	if err := newModel().FitModelWeighted(X, weights); err == nil {
+		t.Error("FitModelWeighted() with negative weight, want error")
+	}
+}
+
+func TestCompressRows(t *testing.T) {
+	X := NewDenseMatrix(5, 2, []float64{
+		1, 2,
+		3, 4,
+		1, 2,
+		1, 3,
+		3, 4,
+	})
+	unique, weights, rows := compressRows(X, []float64{1, 2, 0.5, 1, 1})
+	if want := []float64{1, 2, 3, 4, 1, 3}; !reflect.DeepEqual(unique.RawMatrix().Data, want) {
+		t.Errorf("compressRows() unique = %v, want %v", unique.RawMatrix().Data, want)
+	}
+	if want := []float64{1.5, 3, 1}; !reflect.DeepEqual(weights, want) {
+		t.Errorf("compressRows() weights = %v, want %v", weights, want)
+	}
+	if want := []int{0, 1, 0, 2, 1}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("compressRows() rows = %v, want %v", rows, want)
+	}
+}
+
+func TestKModes_CompressRows(t *testing.T) {
+	dense := randomCategorical(400, 4, 3, 5)
+	compact, err := NewCategoricalMatrixFromDense(dense)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		X        Matrix
+		init     InitializationFunction
+		movedTol float64
+	}{
+		{"cao", dense, InitCao, 0},
+		{"huang", dense, InitHuang, 0},
+		{"random compact", compact, InitRandom, 0},
+		{"moved tolerance", dense, InitHuang, 0.05},
+	}
+	for _, tt := range tests {
+		newModel := func() *KModes {
+			km := NewKModes(HammingDistance, tt.init, 5, 1, 30, [][]float64{{1}}, "")
+			km.Seed = 3
+			km.MovedTolerance = tt.movedTol
+			return km
+		}
+		checkCompressed(t, tt.name, newModel, tt.X)
+	}
+}
+
+// checkCompressed checks that a fit with CompressRows gives the same model as
+// a fit of all rows.
+func checkCompressed(t *testing.T, name string, newModel func() *KModes, X Matrix) {
+	t.Helper()
+	want := newModel()
+	if err := want.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+	got := newModel()
+	got.CompressRows = true
+	if err := got.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got.Labels.RawVector().Data, want.Labels.RawVector().Data) {
+		t.Errorf("%s: compressed labels differ", name)
+	}
+	if !reflect.DeepEqual(got.ClusterCentroids.RawMatrix().Data, want.ClusterCentroids.RawMatrix().Data) {
+		t.Errorf("%s: compressed centroids = %v, want %v", name, got.ClusterCentroids.RawMatrix().Data, want.ClusterCentroids.RawMatrix().Data)
+	}
+	if !reflect.DeepEqual(got.FrequencyTable, want.FrequencyTable) || !reflect.DeepEqual(got.DistanceQuantiles, want.DistanceQuantiles) {
+		t.Errorf("%s: compressed frequency table or distance quantiles differ", name)
+	}
+	if !reflect.DeepEqual(got.LabelsCounter, want.LabelsCounter) || !reflect.DeepEqual(got.ClusterWeights, want.ClusterWeights) {
+		t.Errorf("%s: compressed counters = %v, %v, want %v, %v", name, got.LabelsCounter, got.ClusterWeights, want.LabelsCounter, want.ClusterWeights)
+	}
+	if !reflect.DeepEqual(got.DroppedClusters, want.DroppedClusters) {
+		t.Errorf("%s: compressed dropped clusters = %v, want %v", name, got.DroppedClusters, want.DroppedClusters)
+	}
+	if got.Cost != want.Cost || got.Iterations != want.Iterations || got.StopReason != want.StopReason {
+		t.Errorf("%s: compressed fit cost %v after %d iterations (%v), want %v after %d (%v)",
+			name, got.Cost, got.Iterations, got.StopReason, want.Cost, want.Iterations, want.StopReason)
+	}
+}
+
+func TestKModes_CompressRowsEmptyClusters(t *testing.T) {
+	// Few unique rows and many clusters, so that clusters empty and are
+	// refilled with single copies of rows.
+	X := randomCategorical(300, 3, 2, 7)
+	weights := make([]float64, 300)
+	for i := range weights {
+		weights[i] = float64(1 + i%3)
+	}
+	var dropped int
+	for _, strategy := range []EmptyClusterStrategy{EmptyClusterRandom, EmptyClusterFarthest, EmptyClusterSplitLargest, EmptyClusterDrop} {
+		for seed := int64(0); seed < 50; seed++ {
+			newModel := func() *KModes {
+				km := NewKModes(HammingDistance, InitRandom, 6, 1, 30, [][]float64{{1}}, "")
+				km.Seed = seed
+				km.EmptyClusterAction = strategy
+				return km
+			}
+			name := fmt.Sprintf("strategy %d, seed %d", strategy, seed)
+			checkCompressed(t, name, newModel, X)
+			if km := newModel(); km.FitModel(X) == nil && km.DroppedClusters != nil {
+				dropped++
+			}
+		}
+
+		// Weighted rows are refilled with the weight of the moved copy.
+		fits := make([]*KModes, 2)
+		for i, compress := range []bool{false, true} {
+			fits[i] = NewKModes(HammingDistance, InitRandom, 6, 1, 30, [][]float64{{1}}, "")
+			fits[i].Seed = 1
+			fits[i].EmptyClusterAction = strategy
+			fits[i].CompressRows = compress
+			if err := fits[i].FitModelWeighted(X, weights); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got, want := fits[1], fits[0]; !reflect.DeepEqual(got.Labels, want.Labels) || !reflect.DeepEqual(got.ClusterWeights, want.ClusterWeights) || got.Cost != want.Cost {
+			t.Errorf("strategy %d: weighted compressed fit differs", strategy)
+		}
+	}
+	if dropped == 0 {
+		t.Error("no cluster became empty, refills are not tested")
+	}
+}
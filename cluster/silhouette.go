@@ -0,0 +1,75 @@
+package cluster
+
+import (
+	"errors"
+	"math"
+)
+
+// Silhouette computes silhouette coefficients of clustering described by
+// labels, using precomputed distances D. It returns the mean coefficient and
+// the coefficient of every row. Rows with negative labels (noise) are skipped
+// and get coefficient 0, as do rows of single member clusters.
+func Silhouette(D DistanceMatrix, labels *DenseVector) (float64, []float64, error) {
+	n := D.Len()
+	if labels.Len() != n {
+		return 0, nil, errors.New("silhouette: labels length does not match distance matrix")
+	}
+
+	var clusters int
+	for i := 0; i < n; i++ {
+		if l := int(labels.At(i, 0)); l >= clusters {
+			clusters = l + 1
+		}
+	}
+	counts := make([]int, clusters)
+	for i := 0; i < n; i++ {
+		if l := int(labels.At(i, 0)); l >= 0 {
+			counts[l]++
+		}
+	}
+
+	scores := make([]float64, n)
+	sums := make([]float64, clusters)
+	var total float64
+	var scored int
+	for i := 0; i < n; i++ {
+		own := int(labels.At(i, 0))
+		if own < 0 {
+			continue
+		}
+		scored++
+		if counts[own] < 2 {
+			continue
+		}
+
+		for c := range sums {
+			sums[c] = 0
+		}
+		for j := 0; j < n; j++ {
+			if l := int(labels.At(j, 0)); l >= 0 && j != i {
+				sums[l] += D.Distance(i, j)
+			}
+		}
+
+		a := sums[own] / float64(counts[own]-1)
+		b := math.MaxFloat64
+		for c, sum := range sums {
+			if c != own && counts[c] > 0 && sum/float64(counts[c]) < b {
+				b = sum / float64(counts[c])
+			}
+		}
+		if b == math.MaxFloat64 {
+			// There is only one cluster.
+			continue
+		}
+		if max := math.Max(a, b); max > 0 {
+			scores[i] = (b - a) / max
+		}
+		total += scores[i]
+	}
+
+	if scored == 0 {
+		return 0, scores, nil
+	}
+	return total / float64(scored), scores, nil
+}
@@ -0,0 +1,41 @@
+package cluster
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSilhouette(t *testing.T) {
+	X := NewDenseMatrix(4, 1, []float64{0, 1, 10, 11})
+	D, _ := ComputeDistanceMatrix(X, EuclideanDistance, 1)
+
+	tests := []struct {
+		labels     *DenseVector
+		want       float64
+		wantScores []float64
+		wantErr    bool
+	}{
+		{labels: NewDenseVector(4, []float64{0, 0, 1, 1}), want: 0.899749, wantScores: []float64{0.904762, 0.894737, 0.894737, 0.904762}},
+		{labels: NewDenseVector(4, []float64{0, 1, 1, 1}), want: 0.026316, wantScores: []float64{0, -0.894737, 0.5, 0.5}},
+		{labels: NewDenseVector(4, []float64{0, 0, -1, -1}), want: 0, wantScores: []float64{0, 0, 0, 0}},
+		{labels: NewDenseVector(3, []float64{0, 0, 1}), wantErr: true},
+	}
+	for i, tt := range tests {
+		got, scores, err := Silhouette(D, tt.labels)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%d. Silhouette() error = %v, wantErr %v", i, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("%d. Silhouette() = %v, want %v", i, got, tt.want)
+		}
+		for j, s := range scores {
+			if math.Abs(s-tt.wantScores[j]) > 1e-6 {
+				t.Errorf("%d. Silhouette() score %d = %v, want %v", i, j, s, tt.wantScores[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// checkInitialCentroids validates centroids and fixed clusters of a warm
+// start of a model with the given number of clusters and attributes.
+func checkInitialCentroids(centroids [][]float64, fixed []int, clusters, cols int) error {
+	if centroids == nil {
+		if len(fixed) > 0 {
+			return errors.New("fixed clusters need initial centroids")
+		}
+		return nil
+	}
+	if len(centroids) != clusters {
+		return fmt.Errorf("got %d initial centroids for %d clusters", len(centroids), clusters)
+	}
+	for i, c := range centroids {
+		if len(c) != cols {
+			return fmt.Errorf("initial centroid %d has %d attributes, data has %d", i, len(c), cols)
+		}
+	}
+	for _, c := range fixed {
+		if c < 0 || c >= clusters {
+			return fmt.Errorf("fixed cluster %d out of range", c)
+		}
+	}
+	return nil
+}
+
+// isFixed checks whether the centroid of cluster is kept during fitting.
+func isFixed(fixed []int, cluster int) bool {
+	for _, f := range fixed {
+		if f == cluster {
+			return true
+		}
+	}
+	return false
+}
+
+// centroidMatrix copies rows of centroids into a new matrix.
+func centroidMatrix(centroids [][]float64) *DenseMatrix {
+	m := NewDenseMatrix(len(centroids), len(centroids[0]), nil)
+	for i, c := range centroids {
+		m.SetRow(i, c)
+	}
+	return m
+}
+
+// CentroidRows returns a copy of cluster centroids, which may be used as
+// InitialCentroids of another fit.
+func (km *KModes) CentroidRows() [][]float64 {
+	r, _ := km.ClusterCentroids.Dims()
+	rows := make([][]float64, r)
+	for i := range rows {
+		rows[i] = mat.Row(nil, i, km.ClusterCentroids)
+	}
+	return rows
+}
+
+// CentroidRows returns a copy of cluster centroids in the column order of the
+// data with numerical attributes in original units, which may be used as
+// InitialCentroids of another fit.
+func (km *KPrototypes) CentroidRows() [][]float64 {
+	r, c := km.ClusterCentroids.Dims()
+	numerical, _ := numericalColumns(km.CategoricalInd, c)
+	rows := make([][]float64, r)
+	for i := range rows {
+		rows[i] = mat.Row(nil, i, km.ClusterCentroids)
+		for j, col := range numerical {
+			rows[i][col] *= km.NumericScales[j]
+		}
+	}
+	return rows
+}
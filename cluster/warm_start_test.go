@@ -0,0 +1,105 @@
+package cluster
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckInitialCentroids(t *testing.T) {
+	tests := []struct {
+		centroids [][]float64
+		fixed     []int
+		wantErr   bool
+	}{
+		{nil, nil, false},
+		{[][]float64{{1, 2}, {3, 4}}, []int{1}, false},
+		{nil, []int{0}, true},
+		{[][]float64{{1, 2}}, nil, true},
+		{[][]float64{{1, 2}, {3}}, nil, true},
+		{[][]float64{{1, 2}, {3, 4}}, []int{2}, true},
+	}
+	for i, tt := range tests {
+		if err := checkInitialCentroids(tt.centroids, tt.fixed, 2, 2); (err != nil) != tt.wantErr {
+			t.Errorf("%d. checkInitialCentroids() error = %v, wantErr %v", i, err, tt.wantErr)
+		}
+	}
+}
+
+func TestKModes_InitialCentroids(t *testing.T) {
+	X := randomCategorical(100, 5, 3, 8)
+	prev := NewKModes(HammingDistance, InitHuang, 4, 1, 50, [][]float64{{1}}, "")
+	prev.Seed = 2
+	if err := prev.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+
+	// Starting from a converged model changes nothing.
+	km := NewKModes(HammingDistance, nil, 4, 1, 50, [][]float64{{1}}, "")
+	km.InitialCentroids = prev.CentroidRows()
+	if err := km.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(km.CentroidRows(), prev.CentroidRows()) || !reflect.DeepEqual(km.Labels, prev.Labels) || km.Iterations != 1 {
+		t.Errorf("warm start from converged model = %v after %d iterations, want %v", km.CentroidRows(), km.Iterations, prev.CentroidRows())
+	}
+
+	// A fixed persona keeps its centroid, the last attribute takes a value
+	// missing in the data so that an unfixed persona moves.
+	persona := []float64{0, 1, 2, 0, 5}
+	km.InitialCentroids = [][]float64{persona, {0, 0, 0, 0, 0}, {1, 1, 1, 1, 1}, {2, 2, 2, 2, 2}}
+	km.FixedClusters = []int{0}
+	if err := km.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+	rows := km.CentroidRows()
+	if !reflect.DeepEqual(rows[0], persona) || km.LabelsCounter[0] == 0 {
+		t.Errorf("fixed centroid = %v with %d rows, want %v", rows[0], km.LabelsCounter[0], persona)
+	}
+	km.FixedClusters = nil
+	if err := km.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(km.CentroidRows()[0], persona) {
+		t.Error("unfixed persona kept its centroid")
+	}
+
+	km.InitialCentroids = km.InitialCentroids[:3]
+	if err := km.FitModel(X); err == nil {
+		t.Error("FitModel() with too few initial centroids, want error")
+	}
+}
+
+func TestKPrototypes_InitialCentroids(t *testing.T) {
+	data := []float64{
+		0, 10,
+		0, 20,
+		0, 10,
+		1, 50,
+		1, 60,
+		1, 40,
+	}
+	newModel := func() *KPrototypes {
+		kp := NewKPrototypes(HammingDistance, nil, []int{0}, 2, 1, 20, [][]float64{{1}}, 1, "")
+		kp.Seed = 1
+		return kp
+	}
+	kp := newModel()
+	kp.InitialCentroids = [][]float64{{0, 12}, {1, 45}}
+	if err := kp.FitModel(NewDenseMatrix(6, 2, append([]float64(nil), data...))); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]float64{{0, 40.0 / 3}, {1, 50}}
+	if got := kp.CentroidRows(); !closeFloats(append(got[0], got[1]...), append(want[0], want[1]...)) {
+		t.Errorf("CentroidRows() = %v, want %v", got, want)
+	}
+
+	kp = newModel()
+	kp.InitialCentroids = [][]float64{{0, 12}, {1, 45}}
+	kp.FixedClusters = []int{1}
+	if err := kp.FitModel(NewDenseMatrix(6, 2, append([]float64(nil), data...))); err != nil {
+		t.Fatal(err)
+	}
+	if got := kp.CentroidRows(); !closeFloats(got[1], []float64{1, 45}) {
+		t.Errorf("fixed centroid = %v, want [1 45]", got[1])
+	}
+}
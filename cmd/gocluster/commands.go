@@ -0,0 +1,393 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/e-XpertSolutions/go-cluster/v2/cluster"
+)
+
+// fit trains the model and saves it.
+func fit(args []string, stdout, stderr io.Writer) error {
+	var seedSet bool
+	fs := flag.NewFlagSet("fit", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	schemaPath := fs.String("schema", "", "path to the JSON `schema` of the CSV file (required)")
+	out := fs.String("o", "model.gob", "path of the saved model")
+	algorithm := fs.String("algorithm", "", "kmodes or kprototypes (default kprototypes if the schema has numeric columns, kmodes otherwise)")
+	k := fs.Int("k", 8, "number of clusters")
+	runs := fs.Int("runs", 1, "number of runs with consecutive seeds, the run with the lowest cost is kept, converged runs first")
+	gamma := fs.Float64("gamma", 1, "weight of the categorical part of the distance (kprototypes)")
+	initName := fs.String("init", "cao", "initialization: cao, huang or random; cao-mixed or plusplus-mixed for kprototypes")
+	distName := fs.String("distance", "hamming", "distance of categorical attributes: hamming or weighted-hamming")
+	iters := fs.Int("iters", 100, "maximum number of iterations of a run")
+	seed := fs.Int64("seed", 0, "seed of the first run (default current time)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			seedSet = true
+		}
+	})
+	if !seedSet {
+		*seed = time.Now().UnixNano()
+	}
+	if *schemaPath == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("fit: schema and exactly one CSV file are required")
+	}
+	if *runs < 1 {
+		return errors.New("fit: runs must be at least 1")
+	}
+
+	s, err := readSchema(*schemaPath)
+	if err != nil {
+		return err
+	}
+	categorical := s.categoricalIndexes()
+	kind := *algorithm
+	if kind == "" {
+		kind = kindKModes
+		if len(categorical) < len(s.features()) {
+			kind = kindKPrototypes
+		}
+	}
+	switch kind {
+	case kindKModes:
+		if len(categorical) < len(s.features()) {
+			return errors.New("fit: kmodes works only with categorical columns")
+		}
+	case kindKPrototypes:
+		if len(categorical) == 0 {
+			return errors.New("fit: kprototypes needs at least one categorical column")
+		}
+	default:
+		return fmt.Errorf("fit: unknown algorithm %q", kind)
+	}
+
+	dist, ok := distances[*distName]
+	if !ok {
+		return fmt.Errorf("fit: unknown distance %q", *distName)
+	}
+	init, mixedInit := initializations[*initName], mixedInitializations[*initName]
+	if init == nil && (mixedInit == nil || kind != kindKPrototypes) {
+		return fmt.Errorf("fit: unknown initialization %q for %s", *initName, kind)
+	}
+
+	t, err := readTable(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	m := &modelFile{
+		Kind:         kind,
+		Distance:     *distName,
+		Init:         *initName,
+		Schema:       s,
+		Dictionaries: newDictionaries(s),
+		Rows:         len(t.Records),
+	}
+	X, err := t.encode(s, m.Dictionaries, true)
+	if err != nil {
+		return err
+	}
+
+	// Weights are used only by the weighted distance, they are computed from
+	// categorical attributes.
+	xCat := categoricalPart(X, categorical)
+	_, xCatCols := xCat.Dims()
+	weights := make([]float64, xCatCols)
+	for i := range weights {
+		weights[i] = 1
+	}
+	if *distName == "weighted-hamming" {
+		weights = cluster.ComputeWeights(xCat, 1)
+	}
+
+	for r := 0; r < *runs; r++ {
+		switch kind {
+		case kindKModes:
+			km := cluster.NewKModes(dist, init, *k, 1, *iters, [][]float64{weights}, "")
+			km.Seed = *seed + int64(r)
+			if err := km.FitModel(X); err != nil {
+				return err
+			}
+			if m.KModes == nil || betterRun(km.StopReason, km.Cost, m.KModes.StopReason, m.KModes.Cost) {
+				m.KModes = km
+			}
+		case kindKPrototypes:
+			kp := cluster.NewKPrototypes(dist, init, categorical, *k, 1, *iters, [][]float64{weights}, *gamma, "")
+			kp.MixedInitFunc = mixedInit
+			kp.Seed = *seed + int64(r)
+			if err := kp.FitModel(X); err != nil {
+				return err
+			}
+			if m.KPrototypes == nil || betterRun(kp.StopReason, kp.Cost, m.KPrototypes.StopReason, m.KPrototypes.Cost) {
+				m.KPrototypes = kp
+			}
+		}
+	}
+	// Training labels can be large and are not needed to predict.
+	var cost float64
+	var iterations int
+	var runSeed int64
+	var reason cluster.StopReason
+	if m.KModes != nil {
+		m.KModes.Labels = nil
+		cost, iterations, runSeed, reason = m.KModes.Cost, m.KModes.Iterations, m.KModes.Seed, m.KModes.StopReason
+	} else {
+		m.KPrototypes.Labels = nil
+		cost, iterations, runSeed, reason = m.KPrototypes.Cost, m.KPrototypes.Iterations, m.KPrototypes.Seed, m.KPrototypes.StopReason
+	}
+	if err := m.save(*out); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%s: %d clusters, cost %g after %d iterations (%s, seed %d), saved to %s\n", kind, *k, cost, iterations, reason, runSeed, *out)
+	return nil
+}
+
+// betterRun reports whether a run which stopped with reason and cost is
+// better than the best run so far. Converged runs beat runs which ran out of
+// iterations or time, cost decides otherwise.
+func betterRun(reason cluster.StopReason, cost float64, bestReason cluster.StopReason, bestCost float64) bool {
+	if reason.Converged() != bestReason.Converged() {
+		return reason.Converged()
+	}
+	return cost < bestCost
+}
+
+// categoricalPart returns matrix made of columns of X with given indexes.
+func categoricalPart(X *cluster.DenseMatrix, ind []int) *cluster.DenseMatrix {
+	xRows, xCols := X.Dims()
+	if len(ind) == xCols {
+		return X
+	}
+	xCat := cluster.NewDenseMatrix(xRows, len(ind), nil)
+	for i := 0; i < xRows; i++ {
+		for j, c := range ind {
+			xCat.Set(i, j, X.At(i, c))
+		}
+	}
+	return xCat
+}
+
+// predict labels rows of a CSV file and writes them with a new column holding
+// the cluster.
+func predict(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("predict", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	modelPath := fs.String("model", "", "path to the model saved by fit (required)")
+	out := fs.String("o", "", "path of the labelled CSV (default standard output)")
+	name := fs.String("column", "cluster", "name of the column with labels")
+	novelty := fs.Float64("novelty", 0, "label rows farther from the centroid than this quantile of cluster members' distances with -1, quantile in (0, 1] (0 disables)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *modelPath == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("predict: model and exactly one CSV file are required")
+	}
+
+	m, err := loadModel(*modelPath)
+	if err != nil {
+		return err
+	}
+	if m.Kind == kindKModes {
+		m.KModes.NoveltyQuantile = *novelty
+	} else {
+		m.KPrototypes.NoveltyQuantile = *novelty
+	}
+	t, err := readTable(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	labels, err := m.predict(t)
+	if err != nil {
+		return err
+	}
+
+	w := stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(append(t.Header, *name))
+	for i, record := range t.Records {
+		cw.Write(append(record, strconv.Itoa(int(labels.At(i, 0)))))
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// inspect prints fit statistics, sizes and centroids of clusters.
+func inspect(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("inspect: exactly one model file is required")
+	}
+	m, err := loadModel(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var (
+		seed       int64
+		iterations int
+		cost       float64
+		reason     cluster.StopReason
+		counter    []int
+		dropped    func(i int) bool
+		centroid   func(i, j int) string
+	)
+	features := m.Schema.features()
+	if m.Kind == kindKModes {
+		km := m.KModes
+		seed, iterations, cost, reason = km.Seed, km.Iterations, km.Cost, km.StopReason
+		counter, dropped = km.LabelsCounter, km.IsDropped
+		centroid = func(i, j int) string {
+			return decode(m.Dictionaries[j], km.ClusterCentroids.At(i, j))
+		}
+	} else {
+		kp := m.KPrototypes
+		seed, iterations, cost, reason = kp.Seed, kp.Iterations, kp.Cost, kp.StopReason
+		counter, dropped = kp.LabelsCounter, kp.IsDropped
+		// Positions of features among categorical or numeric centroids.
+		positions := make([]int, len(features))
+		var cat, num int
+		for j, f := range features {
+			if f.Type == columnCategorical {
+				positions[j] = cat
+				cat++
+			} else {
+				positions[j] = num
+				num++
+			}
+		}
+		centroid = func(i, j int) string {
+			if features[j].Type == columnCategorical {
+				return decode(m.Dictionaries[j], kp.ClusterCentroidsCat.At(i, positions[j]))
+			}
+			v := kp.ClusterCentroidsNum.At(i, positions[j]) * kp.NumericScales[positions[j]]
+			return strconv.FormatFloat(v, 'g', 6, 64)
+		}
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "model:\t%s\n", m.Kind)
+	fmt.Fprintf(tw, "distance:\t%s\n", m.Distance)
+	fmt.Fprintf(tw, "initialization:\t%s\n", m.Init)
+	fmt.Fprintf(tw, "training rows:\t%d\n", m.Rows)
+	fmt.Fprintf(tw, "seed:\t%d\n", seed)
+	fmt.Fprintf(tw, "iterations:\t%d\n", iterations)
+	fmt.Fprintf(tw, "cost:\t%g\n", cost)
+	fmt.Fprintf(tw, "stop reason:\t%s\n\n", reason)
+
+	fmt.Fprint(tw, "cluster\tsize")
+	for _, f := range features {
+		fmt.Fprintf(tw, "\t%s", f.Name)
+	}
+	fmt.Fprintln(tw)
+	for i, size := range counter {
+		if dropped(i) {
+			fmt.Fprintf(tw, "%d\tdropped\n", i)
+			continue
+		}
+		fmt.Fprintf(tw, "%d\t%d", i, size)
+		for j := range features {
+			fmt.Fprintf(tw, "\t%s", centroid(i, j))
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// profile describes clusters of rows of a CSV file labelled by the model.
+func profile(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("profile", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	modelPath := fs.String("model", "", "path to the model saved by fit (required)")
+	out := fs.String("o", "", "path of the report (default standard output)")
+	format := fs.String("format", "text", "format of the report: text or markdown")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *modelPath == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("profile: model and exactly one CSV file are required")
+	}
+	if *format != "text" && *format != "markdown" {
+		return fmt.Errorf("profile: unknown format %q", *format)
+	}
+
+	m, err := loadModel(*modelPath)
+	if err != nil {
+		return err
+	}
+	t, err := readTable(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	X, err := t.encode(m.Schema, m.Dictionaries, false)
+	if err != nil {
+		return err
+	}
+	labels, err := m.label(X)
+	if err != nil {
+		return err
+	}
+	var clusters int
+	if m.Kind == kindKModes {
+		clusters = m.KModes.ClustersNumber
+	} else {
+		clusters = m.KPrototypes.ClustersNumber
+	}
+	// Novel rows have negative labels and are left out.
+	p, err := cluster.NewProfile(X, m.Schema.categoricalIndexes(), labels, clusters)
+	if err != nil {
+		return err
+	}
+
+	features := m.Schema.features()
+	names := make([]string, len(features))
+	values := make([]map[float64]string, len(features))
+	for j, f := range features {
+		names[j] = f.Name
+		if m.Dictionaries[j] != nil {
+			values[j] = map[float64]string{-1: "?"}
+			for value, code := range m.Dictionaries[j] {
+				values[j][code] = value
+			}
+		}
+	}
+
+	w := stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	if *format == "markdown" {
+		return p.WriteMarkdown(w, names, values)
+	}
+	return p.WriteText(w, names, values)
+}
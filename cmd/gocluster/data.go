@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/e-XpertSolutions/go-cluster/v2/cluster"
+)
+
+// Types of columns in the schema.
+const (
+	columnCategorical = "categorical"
+	columnNumeric     = "numeric"
+	columnIgnore      = "ignore"
+)
+
+// schema describes columns of CSV files, for example:
+//
+//	{"columns": [
+//		{"name": "country", "type": "categorical"},
+//		{"name": "age", "type": "numeric"},
+//		{"name": "id", "type": "ignore"}
+//	]}
+//
+// Columns are found by their names in the CSV header, columns which are not in
+// the schema are ignored.
+type schema struct {
+	Columns []column `json:"columns"`
+}
+
+type column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+func readSchema(path string) (schema, error) {
+	var s schema
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, fmt.Errorf("schema: %v", err)
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("schema: cannot parse %s: %v", path, err)
+	}
+	return s, s.validate()
+}
+
+func (s schema) validate() error {
+	var used int
+	names := make(map[string]bool)
+	for _, c := range s.Columns {
+		if names[c.Name] {
+			return fmt.Errorf("schema: duplicated column %q", c.Name)
+		}
+		names[c.Name] = true
+		switch c.Type {
+		case columnCategorical, columnNumeric:
+			used++
+		case columnIgnore:
+		default:
+			return fmt.Errorf("schema: unknown type %q of column %q", c.Type, c.Name)
+		}
+	}
+	if used == 0 {
+		return errors.New("schema: no categorical or numeric columns")
+	}
+	return nil
+}
+
+// features returns used (not ignored) columns, in the order of the schema.
+func (s schema) features() []column {
+	var features []column
+	for _, c := range s.Columns {
+		if c.Type != columnIgnore {
+			features = append(features, c)
+		}
+	}
+	return features
+}
+
+// categoricalIndexes returns positions of categorical columns among features.
+func (s schema) categoricalIndexes() []int {
+	var ind []int
+	for i, c := range s.features() {
+		if c.Type == columnCategorical {
+			ind = append(ind, i)
+		}
+	}
+	return ind
+}
+
+// table is a CSV file with header.
+type table struct {
+	Header  []string
+	Records [][]string
+}
+
+func readTable(path string) (*table, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseTable(file)
+}
+
+func parseTable(r io.Reader) (*table, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("csv: %v", err)
+	}
+	if len(records) < 2 {
+		return nil, errors.New("csv: header and at least one row are required")
+	}
+	return &table{Header: records[0], Records: records[1:]}, nil
+}
+
+// encode converts features of the table to the matrix. Categorical values are
+// replaced by their codes from dictionaries, one dictionary per feature (nil
+// for numeric ones). If grow is set, new values are added to dictionaries,
+// otherwise unknown values get code -1 which does not match any centroid.
+func (t *table) encode(s schema, dictionaries []map[string]float64, grow bool) (*cluster.DenseMatrix, error) {
+	features := s.features()
+	positions := make([]int, len(features))
+	for i, f := range features {
+		positions[i] = -1
+		for j, name := range t.Header {
+			if name == f.Name {
+				positions[i] = j
+			}
+		}
+		if positions[i] < 0 {
+			return nil, fmt.Errorf("csv: column %q not found", f.Name)
+		}
+	}
+
+	X := cluster.NewDenseMatrix(len(t.Records), len(features), nil)
+	for i, record := range t.Records {
+		for j, f := range features {
+			value := record[positions[j]]
+			if f.Type == columnNumeric {
+				v, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					return nil, fmt.Errorf("csv: row %d, column %q: %v", i+2, f.Name, err)
+				}
+				X.Set(i, j, v)
+				continue
+			}
+			code, ok := dictionaries[j][value]
+			if !ok {
+				code = -1
+				if grow {
+					code = float64(len(dictionaries[j]))
+					dictionaries[j][value] = code
+				}
+			}
+			X.Set(i, j, code)
+		}
+	}
+	return X, nil
+}
+
+// newDictionaries creates empty dictionaries for categorical features.
+func newDictionaries(s schema) []map[string]float64 {
+	features := s.features()
+	dictionaries := make([]map[string]float64, len(features))
+	for i, f := range features {
+		if f.Type == columnCategorical {
+			dictionaries[i] = make(map[string]float64)
+		}
+	}
+	return dictionaries
+}
+
+// decode returns the value which has given code in dictionary.
+func decode(dictionary map[string]float64, code float64) string {
+	for value, c := range dictionary {
+		if c == code {
+			return value
+		}
+	}
+	return "?"
+}
@@ -0,0 +1,65 @@
+// Command gocluster trains k-modes and k-prototypes models on CSV files and
+// uses them to label new data, without writing any Go code.
+//
+// Usage:
+//
+//	gocluster fit -schema schema.json [flags] data.csv
+//	gocluster predict -model model.gob [flags] data.csv
+//	gocluster inspect model.gob
+//	gocluster profile -model model.gob [flags] data.csv
+//
+// The schema is a JSON file describing which CSV columns are categorical,
+// numeric or ignored. Categorical values are dictionary-encoded during fit and
+// dictionaries are saved in the model file, so predict reads the same CSV
+// format. Run "gocluster <command> -h" for flags of each command.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `usage:
+	gocluster fit -schema schema.json [flags] data.csv
+	gocluster predict -model model.gob [flags] data.csv
+	gocluster inspect model.gob
+	gocluster profile -model model.gob [flags] data.csv
+
+Run "gocluster <command> -h" for flags of each command.
+`
+
+func main() {
+	err := run(os.Args[1:], os.Stdout, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gocluster:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return errors.New("command is missing")
+	}
+	switch args[0] {
+	case "fit":
+		return fit(args[1:], stdout, stderr)
+	case "predict":
+		return predict(args[1:], stdout, stderr)
+	case "inspect":
+		return inspect(args[1:], stdout, stderr)
+	case "profile":
+		return profile(args[1:], stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	}
+	fmt.Fprint(stderr, usage)
+	return fmt.Errorf("unknown command %q", args[0])
+}
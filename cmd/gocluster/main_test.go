@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSchema = `{"columns": [
+	{"name": "id", "type": "ignore"},
+	{"name": "color", "type": "categorical"},
+	{"name": "shape", "type": "categorical"},
+	{"name": "size", "type": "numeric"}
+]}`
+
+// writeTestData writes two obvious groups of rows and the schema into dir.
+func writeTestData(t *testing.T, dir string) (string, string) {
+	var data strings.Builder
+	data.WriteString("id,color,shape,size\n")
+	for i := 0; i < 20; i++ {
+		if i%2 == 0 {
+			fmt.Fprintf(&data, "%d,blue,square,%d\n", i, 20+i%5)
+		} else {
+			fmt.Fprintf(&data, "%d,red,round,%d\n", i, 1+i%3)
+		}
+	}
+	dataPath := filepath.Join(dir, "data.csv")
+	schemaPath := filepath.Join(dir, "schema.json")
+	if err := os.WriteFile(dataPath, []byte(data.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(schemaPath, []byte(testSchema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dataPath, schemaPath
+}
+
+func TestFitPredictInspect(t *testing.T) {
+	dir := t.TempDir()
+	dataPath, schemaPath := writeTestData(t, dir)
+
+	tests := []struct {
+		args      []string
+		wantKind  string
+		wantTable string
+	}{
+		{
+			args:      []string{"-k", "2", "-seed", "1"},
+			wantKind:  "kprototypes",
+			wantTable: "0        10    blue   square  22",
+		},
+		{
+			args:      []string{"-k", "2", "-seed", "1", "-runs", "3", "-init", "plusplus-mixed", "-distance", "weighted-hamming"},
+			wantKind:  "kprototypes",
+			wantTable: "10    red    round   2\n",
+		},
+	}
+	for i, tt := range tests {
+		modelPath := filepath.Join(dir, "model.gob")
+		var stdout bytes.Buffer
+		args := append([]string{"fit", "-schema", schemaPath, "-o", modelPath}, tt.args...)
+		if err := run(append(args, dataPath), &stdout, &stdout); err != nil {
+			t.Fatalf("%d. fit error = %v", i, err)
+		}
+
+		stdout.Reset()
+		if err := run([]string{"inspect", modelPath}, &stdout, &stdout); err != nil {
+			t.Fatalf("%d. inspect error = %v", i, err)
+		}
+		if got := stdout.String(); !strings.Contains(got, tt.wantKind) || !strings.Contains(got, tt.wantTable) {
+			t.Errorf("%d. inspect output:\n%s\nwant %q and %q", i, got, tt.wantKind, tt.wantTable)
+		}
+
+		stdout.Reset()
+		if err := run([]string{"predict", "-model", modelPath, dataPath}, &stdout, &stdout); err != nil {
+			t.Fatalf("%d. predict error = %v", i, err)
+		}
+		lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+		if len(lines) != 21 || lines[0] != "id,color,shape,size,cluster" {
+			t.Fatalf("%d. predict output:\n%s", i, stdout.String())
+		}
+		// Rows of the same group must share the cluster.
+		for j := 3; j < len(lines); j++ {
+			if lines[j][len(lines[j])-1] != lines[j-2][len(lines[j-2])-1] {
+				t.Errorf("%d. predict rows %q and %q are in different clusters", i, lines[j-2], lines[j])
+			}
+		}
+
+		stdout.Reset()
+		if err := run([]string{"predict", "-model", modelPath, "-novelty", "1", dataPath}, &stdout, &stdout); err != nil {
+			t.Fatalf("%d. predict -novelty error = %v", i, err)
+		}
+		if strings.Contains(stdout.String(), ",-1\n") {
+			t.Errorf("%d. predict -novelty 1 flags training rows:\n%s", i, stdout.String())
+		}
+	}
+}
+
+func TestProfile(t *testing.T) {
+	dir := t.TempDir()
+	dataPath, schemaPath := writeTestData(t, dir)
+	modelPath := filepath.Join(dir, "model.gob")
+	var stdout bytes.Buffer
+	if err := run([]string{"fit", "-schema", schemaPath, "-o", modelPath, "-k", "2", "-seed", "1", dataPath}, &stdout, &stdout); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{"text", []string{"Cluster 0: 10 rows (50.0%)", "color      blue    10     100.0%  2.00", "size       22"}},
+		{"markdown", []string{"## Cluster 1", "| shape | round | 10 | 100.0% | 2.00 |", "| size | 2 |"}},
+	}
+	for _, tt := range tests {
+		stdout.Reset()
+		if err := run([]string{"profile", "-model", modelPath, "-format", tt.format, dataPath}, &stdout, &stdout); err != nil {
+			t.Fatalf("profile -format %s error = %v", tt.format, err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(stdout.String(), want) {
+				t.Errorf("profile -format %s output does not contain %q:\n%s", tt.format, want, stdout.String())
+			}
+		}
+	}
+}
+
+func TestPredictUnknownValue(t *testing.T) {
+	dir := t.TempDir()
+	dataPath, _ := writeTestData(t, dir)
+	schemaPath := filepath.Join(dir, "categorical.json")
+	os.WriteFile(schemaPath, []byte(`{"columns": [{"name": "color", "type": "categorical"}, {"name": "shape", "type": "categorical"}]}`), 0o644)
+	modelPath := filepath.Join(dir, "model.gob")
+
+	var stdout bytes.Buffer
+	if err := run([]string{"fit", "-schema", schemaPath, "-o", modelPath, "-k", "2", "-seed", "1", dataPath}, &stdout, &stdout); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(stdout.String(), "kmodes: 2 clusters") {
+		t.Errorf("fit output = %q", stdout.String())
+	}
+
+	newPath := filepath.Join(dir, "new.csv")
+	os.WriteFile(newPath, []byte("shape,color\nround,green\n"), 0o644)
+	stdout.Reset()
+	if err := run([]string{"predict", "-model", modelPath, newPath}, &stdout, &stdout); err != nil {
+		t.Fatal(err)
+	}
+	var want bytes.Buffer
+	run([]string{"predict", "-model", modelPath, dataPath}, &want, &want)
+	// Unknown color matches no centroid, so the shape decides.
+	if label := strings.Split(want.String(), "\n")[2]; !strings.HasSuffix(strings.TrimSpace(stdout.String()), label[len(label)-1:]) {
+		t.Errorf("predict output = %q, want cluster of round rows %q", stdout.String(), label)
+	}
+}
+
+func TestFitIterationBudget(t *testing.T) {
+	dir := t.TempDir()
+	dataPath, schemaPath := writeTestData(t, dir)
+	modelPath := filepath.Join(dir, "model.gob")
+
+	// A run cut by the iteration budget is kept and can predict.
+	var stdout bytes.Buffer
+	if err := run([]string{"fit", "-schema", schemaPath, "-o", modelPath, "-iters", "1", "-k", "20", dataPath}, &stdout, &stdout); err != nil {
+		t.Fatalf("fit error = %v", err)
+	}
+	if !strings.Contains(stdout.String(), "after 1 iterations (max iterations") {
+		t.Errorf("fit output = %q, want stop by max iterations", stdout.String())
+	}
+	stdout.Reset()
+	if err := run([]string{"predict", "-model", modelPath, dataPath}, &stdout, &stdout); err != nil {
+		t.Fatalf("predict error = %v", err)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	dir := t.TempDir()
+	dataPath, schemaPath := writeTestData(t, dir)
+	badSchema := filepath.Join(dir, "bad.json")
+	os.WriteFile(badSchema, []byte(`{"columns": [{"name": "color", "type": "text"}]}`), 0o644)
+
+	tests := [][]string{
+		{},
+		{"train"},
+		{"fit", dataPath},
+		{"fit", "-schema", badSchema, dataPath},
+		{"fit", "-schema", schemaPath, "-algorithm", "kmodes", dataPath},
+		{"fit", "-schema", schemaPath, "-distance", "cosine", dataPath},
+		{"fit", "-schema", schemaPath, "-init", "unknown", dataPath},
+		{"predict", "-model", filepath.Join(dir, "missing.gob"), dataPath},
+		{"inspect"},
+		{"profile", "-model", filepath.Join(dir, "missing.gob"), dataPath},
+		{"profile", "-model", filepath.Join(dir, "missing.gob"), "-format", "html", dataPath},
+	}
+	for _, args := range tests {
+		var out bytes.Buffer
+		if err := run(args, &out, &out); err == nil {
+			t.Errorf("run(%q) did not fail", args)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+
+	"github.com/e-XpertSolutions/go-cluster/v2/cluster"
+)
+
+// Kinds of models.
+const (
+	kindKModes      = "kmodes"
+	kindKPrototypes = "kprototypes"
+)
+
+var distances = map[string]cluster.DistanceFunction{
+	"hamming":          cluster.HammingDistance,
+	"weighted-hamming": cluster.WeightedHammingDistance,
+}
+
+var initializations = map[string]cluster.InitializationFunction{
+	"cao":    cluster.InitCao,
+	"huang":  cluster.InitHuang,
+	"random": cluster.InitRandom,
+}
+
+// mixedInitializations are available only for k-prototypes.
+var mixedInitializations = map[string]cluster.MixedInitializationFunction{
+	"cao-mixed":      cluster.InitCaoMixed,
+	"plusplus-mixed": cluster.InitPlusPlusMixed,
+}
+
+// modelFile is the content of files written by fit. Functions are not
+// serialized, so distance and initialization are stored by name together with
+// schema and dictionaries needed to encode new data.
+type modelFile struct {
+	Kind         string
+	Distance     string
+	Init         string
+	Schema       schema
+	Dictionaries []map[string]float64
+	Rows         int // number of training rows
+	KModes       *cluster.KModes
+	KPrototypes  *cluster.KPrototypes
+}
+
+func (m *modelFile) save(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(file).Encode(m); err != nil {
+		file.Close()
+		return fmt.Errorf("model: cannot encode: %v", err)
+	}
+	return file.Close()
+}
+
+func loadModel(path string) (*modelFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// Matrices can be decoded only into allocated ones, as in LoadModel of
+	// the models.
+	m := &modelFile{
+		KModes:      cluster.NewKModes(nil, nil, 0, 0, 0, nil, ""),
+		KPrototypes: cluster.NewKPrototypes(nil, nil, nil, 0, 0, 0, nil, 0, ""),
+	}
+	if err := gob.NewDecoder(file).Decode(m); err != nil {
+		return nil, fmt.Errorf("model: cannot decode %s: %v", path, err)
+	}
+	dist, ok := distances[m.Distance]
+	if !ok {
+		return nil, fmt.Errorf("model: unknown distance %q", m.Distance)
+	}
+	switch {
+	case m.Kind == kindKModes:
+		m.KPrototypes = nil
+		m.KModes.DistanceFunc = dist
+		m.KModes.InitializationFunc = initializations[m.Init]
+	case m.Kind == kindKPrototypes:
+		m.KModes = nil
+		m.KPrototypes.DistanceFunc = dist
+		m.KPrototypes.InitializationFunc = initializations[m.Init]
+		m.KPrototypes.MixedInitFunc = mixedInitializations[m.Init]
+	default:
+		return nil, fmt.Errorf("model: unknown kind %q", m.Kind)
+	}
+	return m, nil
+}
+
+// predict labels rows of the table.
+func (m *modelFile) predict(t *table) (*cluster.DenseVector, error) {
+	X, err := t.encode(m.Schema, m.Dictionaries, false)
+	if err != nil {
+		return nil, err
+	}
+	return m.label(X)
+}
+
+// label predicts labels of encoded rows.
+func (m *modelFile) label(X *cluster.DenseMatrix) (*cluster.DenseVector, error) {
+	if m.Kind == kindKModes {
+		return m.KModes.Predict(X)
+	}
+	return m.KPrototypes.Predict(X)
+}
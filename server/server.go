@@ -0,0 +1,385 @@
+// Package server serves fitted KModes and KPrototypes models over HTTP.
+//
+// Handler offers these JSON endpoints:
+//
+//	POST /predict        {"row": [1, 2, 3]}           -> {"cluster": 0}
+//	POST /predict/batch  {"rows": [[1, 2, 3], ...]}   -> {"clusters": [0, ...]}
+//	POST /distances      {"rows": [[1, 2, 3], ...]}   -> {"distances": [[0.5, 2, ...], ...], "margins": [1.5, ...]}
+//	GET  /metadata                                    -> Metadata
+//
+// Rows hold encoded attributes in the same order as the training data.
+// Distances to dropped clusters are null. Margins are differences between
+// distances to the second nearest and the nearest cluster (null if there is no
+// second cluster). POST /distances?temperature=T adds "probabilities" computed
+// with cluster.SoftAssignments. Errors are reported as {"error": "..."} with a
+// 4xx or 5xx status.
+//
+// The model may be replaced while the handler serves requests: Reload loads a
+// new model with the Loader and swaps it, requests which already started are
+// completed with the previous model.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/e-XpertSolutions/go-cluster/v2/cluster"
+)
+
+// maxBodySize limits the size of request bodies.
+const maxBodySize = 32 << 20
+
+// Model is a fitted model served by Handler, exactly one of the fields must
+// be set.
+type Model struct {
+	KModes      *cluster.KModes
+	KPrototypes *cluster.KPrototypes
+}
+
+// Loader returns the model to serve, it is called by NewHandler and on every
+// reload.
+type Loader func() (*Model, error)
+
+// KModesFile returns Loader of KModes saved with SaveModel in the file. Distance
+// functions are not saved with the model, so dist must be given.
+func KModesFile(path string, dist cluster.DistanceFunction) Loader {
+	return func() (*Model, error) {
+		km := cluster.NewKModes(dist, nil, 0, 0, 0, nil, path)
+		if err := km.LoadModel(); err != nil {
+			return nil, err
+		}
+		return &Model{KModes: km}, nil
+	}
+}
+
+// KPrototypesFile returns Loader of KPrototypes saved with SaveModel in the
+// file. Distance functions are not saved with the model, so dist must be
+// given.
+func KPrototypesFile(path string, dist cluster.DistanceFunction) Loader {
+	return func() (*Model, error) {
+		kp := cluster.NewKPrototypes(dist, nil, nil, 0, 0, 0, nil, 0, path)
+		if err := kp.LoadModel(); err != nil {
+			return nil, err
+		}
+		return &Model{KPrototypes: kp}, nil
+	}
+}
+
+func (m *Model) validate() error {
+	switch {
+	case m == nil || (m.KModes == nil) == (m.KPrototypes == nil):
+		return errors.New("exactly one of KModes and KPrototypes must be set")
+	case m.KModes != nil && !m.KModes.IsFitted, m.KPrototypes != nil && !m.KPrototypes.IsFitted:
+		return errors.New("model is not fitted")
+	}
+	return nil
+}
+
+// attributes returns the number of attributes of rows.
+func (m *Model) attributes() int {
+	if m.KModes != nil {
+		_, cols := m.KModes.ClusterCentroids.Dims()
+		return cols
+	}
+	_, cols := m.KPrototypes.ClusterCentroidsNum.Dims()
+	return cols + len(m.KPrototypes.CategoricalInd)
+}
+
+func (m *Model) predict(X *cluster.DenseMatrix) (*cluster.DenseVector, error) {
+	if m.KModes != nil {
+		return m.KModes.Predict(X)
+	}
+	return m.KPrototypes.Predict(X)
+}
+
+func (m *Model) transform(X *cluster.DenseMatrix) (*cluster.DenseMatrix, error) {
+	if m.KModes != nil {
+		return m.KModes.Transform(X)
+	}
+	return m.KPrototypes.Transform(X)
+}
+
+// Metadata describes the served model.
+type Metadata struct {
+	Kind            string    `json:"kind"` // "kmodes" or "kprototypes"
+	Clusters        int       `json:"clusters"`
+	Attributes      int       `json:"attributes"`
+	Categorical     []int     `json:"categorical,omitempty"` // indexes of categorical attributes of k-prototypes
+	Gamma           float64   `json:"gamma,omitempty"`
+	ClusterSizes    []int     `json:"cluster_sizes"`
+	DroppedClusters []int     `json:"dropped_clusters,omitempty"`
+	Iterations      int       `json:"iterations"`
+	Cost            float64   `json:"cost"`
+	StopReason      string    `json:"stop_reason"`
+	LoadedAt        time.Time `json:"loaded_at"`
+}
+
+func (m *Model) metadata() Metadata {
+	md := Metadata{Attributes: m.attributes()}
+	if km := m.KModes; km != nil {
+		md.Kind = "kmodes"
+		md.Clusters, md.ClusterSizes, md.DroppedClusters = km.ClustersNumber, km.LabelsCounter, km.DroppedClusters
+		md.Iterations, md.Cost, md.StopReason = km.Iterations, km.Cost, km.StopReason.String()
+		return md
+	}
+	kp := m.KPrototypes
+	md.Kind = "kprototypes"
+	md.Categorical, md.Gamma = kp.CategoricalInd, kp.Gamma
+	md.Clusters, md.ClusterSizes, md.DroppedClusters = kp.ClustersNumber, kp.LabelsCounter, kp.DroppedClusters
+	md.Iterations, md.Cost, md.StopReason = kp.Iterations, kp.Cost, kp.StopReason.String()
+	return md
+}
+
+// Handler is http.Handler serving predictions of the model.
+type Handler struct {
+	load Loader
+	mux  *http.ServeMux
+
+	mu       sync.RWMutex
+	model    *Model
+	loadedAt time.Time
+}
+
+// NewHandler loads the model with load and creates Handler serving it.
+func NewHandler(load Loader) (*Handler, error) {
+	h := &Handler{load: load, mux: http.NewServeMux()}
+	if err := h.Reload(); err != nil {
+		return nil, err
+	}
+	h.mux.HandleFunc("/predict", h.handlePredict)
+	h.mux.HandleFunc("/predict/batch", h.handlePredictBatch)
+	h.mux.HandleFunc("/distances", h.handleDistances)
+	h.mux.HandleFunc("/metadata", h.handleMetadata)
+	return h, nil
+}
+
+// Reload loads the model again and replaces the served one. If loading fails,
+// the previous model is kept.
+func (h *Handler) Reload() error {
+	m, err := h.load()
+	if err != nil {
+		return fmt.Errorf("server: cannot load model: %v", err)
+	}
+	if err := m.validate(); err != nil {
+		return fmt.Errorf("server: cannot load model: %v", err)
+	}
+	h.mu.Lock()
+	h.model, h.loadedAt = m, time.Now()
+	h.mu.Unlock()
+	return nil
+}
+
+// Watch reloads the model whenever modification time or size of the file at
+// path changes, the file is checked every interval until stop is closed.
+// Reload errors are passed to onError, which may be nil. The file should be
+// replaced atomically (for example by renaming a new file), otherwise a
+// partially written file may fail to load until the next change.
+func (h *Handler) Watch(path string, interval time.Duration, stop <-chan struct{}, onError func(error)) {
+	var modTime time.Time
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		modTime, size = info.ModTime(), info.Size()
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(path)
+		if err != nil || (info.ModTime().Equal(modTime) && info.Size() == size) {
+			continue
+		}
+		modTime, size = info.ModTime(), info.Size()
+		if err := h.Reload(); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+// current returns the served model.
+func (h *Handler) current() (*Model, time.Time) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.model, h.loadedAt
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+type predictRequest struct {
+	Row  []float64   `json:"row,omitempty"`
+	Rows [][]float64 `json:"rows,omitempty"`
+}
+
+func (h *Handler) handlePredict(w http.ResponseWriter, r *http.Request) {
+	var req predictRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	m, _ := h.current()
+	labels, ok := predictRows(w, m, [][]float64{req.Row})
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Cluster int `json:"cluster"`
+	}{labels[0]})
+}
+
+func (h *Handler) handlePredictBatch(w http.ResponseWriter, r *http.Request) {
+	var req predictRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	m, _ := h.current()
+	labels, ok := predictRows(w, m, req.Rows)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Clusters []int `json:"clusters"`
+	}{labels})
+}
+
+func (h *Handler) handleDistances(w http.ResponseWriter, r *http.Request) {
+	var req predictRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	m, _ := h.current()
+	X, ok := rowsMatrix(w, m, req.Rows)
+	if !ok {
+		return
+	}
+	var temperature float64
+	if t := r.URL.Query().Get("temperature"); t != "" {
+		var err error
+		if temperature, err = strconv.ParseFloat(t, 64); err != nil || temperature <= 0 {
+			writeError(w, http.StatusBadRequest, "temperature must be a positive number")
+			return
+		}
+	}
+	D, err := m.transform(X)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var resp struct {
+		Distances     [][]*float64 `json:"distances"`
+		Margins       []*float64   `json:"margins"`
+		Probabilities [][]float64  `json:"probabilities,omitempty"`
+	}
+	rows, _ := D.Dims()
+	margins := cluster.Margins(D)
+	resp.Distances = make([][]*float64, rows)
+	resp.Margins = make([]*float64, rows)
+	for i := range resp.Distances {
+		resp.Distances[i] = finite(D.RawRowView(i))
+		resp.Margins[i] = finite([]float64{margins.At(i, 0)})[0]
+	}
+	if temperature > 0 {
+		P, _ := cluster.SoftAssignments(D, temperature)
+		resp.Probabilities = make([][]float64, rows)
+		for i := range resp.Probabilities {
+			resp.Probabilities[i] = P.RawRowView(i)
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// finite replaces infinite values, which cannot be encoded in JSON, by nil.
+func finite(values []float64) []*float64 {
+	result := make([]*float64, len(values))
+	for i, v := range values {
+		if !math.IsInf(v, 0) {
+			v := v
+			result[i] = &v
+		}
+	}
+	return result
+}
+
+func (h *Handler) handleMetadata(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	m, loadedAt := h.current()
+	md := m.metadata()
+	md.LoadedAt = loadedAt
+	writeJSON(w, http.StatusOK, md)
+}
+
+// predictRows labels rows with the model, errors are written to w.
+func predictRows(w http.ResponseWriter, m *Model, rows [][]float64) ([]int, bool) {
+	X, ok := rowsMatrix(w, m, rows)
+	if !ok {
+		return nil, false
+	}
+	labels, err := m.predict(X)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	result := make([]int, len(rows))
+	for i := range result {
+		result[i] = int(labels.At(i, 0))
+	}
+	return result, true
+}
+
+// rowsMatrix checks rows and puts them in the matrix, errors are written to w.
+func rowsMatrix(w http.ResponseWriter, m *Model, rows [][]float64) (*cluster.DenseMatrix, bool) {
+	if len(rows) == 0 {
+		writeError(w, http.StatusBadRequest, "no rows")
+		return nil, false
+	}
+	cols := m.attributes()
+	data := make([]float64, 0, len(rows)*cols)
+	for i, row := range rows {
+		if len(row) != cols {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("row %d has %d attributes, want %d", i, len(row), cols))
+			return nil, false
+		}
+		data = append(data, row...)
+	}
+	return cluster.NewDenseMatrix(len(rows), cols, data), true
+}
+
+// decodeRequest decodes JSON body of POST request, errors are written to w.
+func decodeRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return false
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{msg})
+}
@@ -0,0 +1,398 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/e-XpertSolutions/go-cluster/v2/cluster"
+)
+
+// testData returns rows with three categorical attributes.
+func testData(rows int) *cluster.DenseMatrix {
+	rnd := rand.New(rand.NewSource(1))
+	data := make([]float64, rows*3)
+	for i := range data {
+		data[i] = float64(rnd.Intn(4))
+	}
+	return cluster.NewDenseMatrix(rows, 3, data)
+}
+
+// saveKModes fits KModes with k clusters and saves it to path.
+func saveKModes(t *testing.T, X *cluster.DenseMatrix, k int, path string) *cluster.KModes {
+	km := cluster.NewKModes(cluster.HammingDistance, cluster.InitCao, k, 1, 50, [][]float64{{1, 1, 1}}, path)
+	km.Seed = 1
+	if err := km.FitModel(X); err != nil {
+		t.Fatal(err)
+	}
+	if err := km.SaveModel(); err != nil {
+		t.Fatal(err)
+	}
+	return km
+}
+
+func post(t *testing.T, url string, body interface{}, v interface{}) int {
+	data, _ := json.Marshal(body)
+	resp, err := http.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		json.NewDecoder(resp.Body).Decode(v)
+	}
+	return resp.StatusCode
+}
+
+func getMetadata(t *testing.T, url string) Metadata {
+	resp, err := http.Get(url + "/metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var md Metadata
+	if err := json.NewDecoder(resp.Body).Decode(&md); err != nil {
+		t.Fatal(err)
+	}
+	return md
+}
+
+func TestHandler_KModes(t *testing.T) {
+	X := testData(100)
+	path := filepath.Join(t.TempDir(), "model.gob")
+	km := saveKModes(t, X, 4, path)
+	want, _ := km.Predict(X)
+	wantDist, _ := km.Transform(X)
+
+	h, err := NewHandler(KModesFile(path, cluster.HammingDistance))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	var single struct{ Cluster int }
+	if status := post(t, srv.URL+"/predict", map[string]interface{}{"row": X.RawRowView(7)}, &single); status != http.StatusOK {
+		t.Fatalf("POST /predict status = %d", status)
+	}
+	if single.Cluster != int(want.At(7, 0)) {
+		t.Errorf("POST /predict cluster = %d, want %v", single.Cluster, want.At(7, 0))
+	}
+
+	rows := make([][]float64, 100)
+	for i := range rows {
+		rows[i] = X.RawRowView(i)
+	}
+	var batch struct{ Clusters []int }
+	if status := post(t, srv.URL+"/predict/batch", map[string]interface{}{"rows": rows}, &batch); status != http.StatusOK {
+		t.Fatalf("POST /predict/batch status = %d", status)
+	}
+	for i, c := range batch.Clusters {
+		if c != int(want.At(i, 0)) {
+			t.Errorf("POST /predict/batch cluster %d = %d, want %v", i, c, want.At(i, 0))
+		}
+	}
+
+	var distances struct {
+		Distances     [][]float64
+		Margins       []float64
+		Probabilities [][]float64
+	}
+	if status := post(t, srv.URL+"/distances?temperature=0.5", map[string]interface{}{"rows": rows[:2]}, &distances); status != http.StatusOK {
+		t.Fatalf("POST /distances status = %d", status)
+	}
+	wantMargins := cluster.Margins(wantDist)
+	wantProb, _ := cluster.SoftAssignments(wantDist, 0.5)
+	for i, row := range distances.Distances {
+		if !reflect.DeepEqual(row, wantDist.RawRowView(i)) {
+			t.Errorf("POST /distances row %d = %v, want %v", i, row, wantDist.RawRowView(i))
+		}
+		if distances.Margins[i] != wantMargins.At(i, 0) {
+			t.Errorf("POST /distances margin %d = %v, want %v", i, distances.Margins[i], wantMargins.At(i, 0))
+		}
+		if !reflect.DeepEqual(distances.Probabilities[i], wantProb.RawRowView(i)) {
+			t.Errorf("POST /distances probabilities %d = %v, want %v", i, distances.Probabilities[i], wantProb.RawRowView(i))
+		}
+	}
+
+	md := getMetadata(t, srv.URL)
+	if md.Kind != "kmodes" || md.Clusters != 4 || md.Attributes != 3 || !reflect.DeepEqual(md.ClusterSizes, km.LabelsCounter) || md.StopReason != km.StopReason.String() {
+		t.Errorf("GET /metadata = %+v", md)
+	}
+}
+
+func TestHandler_KPrototypes(t *testing.T) {
+	X := cluster.NewDenseMatrix(6, 2, []float64{
+		1, 10,
+		1, 20,
+		1, 30,
+		2, 70,
+		2, 80,
+		2, 100,
+	})
+	path := filepath.Join(t.TempDir(), "model.gob")
+	kp := cluster.NewKPrototypes(cluster.HammingDistance, cluster.InitCao, []int{0}, 2, 1, 10, [][]float64{{1}}, 1, path)
+	kp.Seed = 1
+	if err := kp.FitModel(cluster.NewDenseMatrix(6, 2, append([]float64(nil), X.RawMatrix().Data...))); err != nil {
+		t.Fatal(err)
+	}
+	if err := kp.SaveModel(); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := NewHandler(KPrototypesFile(path, cluster.HammingDistance))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	for i := 0; i < 6; i++ {
+		var single struct{ Cluster int }
+		post(t, srv.URL+"/predict", map[string]interface{}{"row": X.RawRowView(i)}, &single)
+		if single.Cluster != int(kp.Labels.At(i, 0)) {
+			t.Errorf("POST /predict row %d cluster = %d, want %v", i, single.Cluster, kp.Labels.At(i, 0))
+		}
+	}
+	if md := getMetadata(t, srv.URL); md.Kind != "kprototypes" || md.Attributes != 2 || !reflect.DeepEqual(md.Categorical, []int{0}) {
+		t.Errorf("GET /metadata = %+v", md)
+	}
+}
+
+func TestHandler_Errors(t *testing.T) {
+	X := testData(50)
+	km := cluster.NewKModes(cluster.HammingDistance, cluster.InitCao, 3, 1, 50, [][]float64{{1, 1, 1}}, "")
+	km.Seed = 1
+	km.FitModel(X)
+	km.DroppedClusters = []int{2}
+	h, err := NewHandler(func() (*Model, error) { return &Model{KModes: km}, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	tests := []struct {
+		path string
+		body interface{}
+		want int
+	}{
+		{path: "/predict", body: map[string]interface{}{"row": []float64{1, 2}}, want: http.StatusBadRequest},
+		{path: "/predict", body: "row", want: http.StatusBadRequest},
+		{path: "/predict/batch", body: map[string]interface{}{}, want: http.StatusBadRequest},
+		{path: "/distances", body: map[string]interface{}{"rows": [][]float64{{1, 2, 3}, {1}}}, want: http.StatusBadRequest},
+		{path: "/unknown", body: map[string]interface{}{}, want: http.StatusNotFound},
+		{path: "/metadata", body: map[string]interface{}{}, want: http.StatusMethodNotAllowed},
+		{path: "/distances?temperature=-1", body: map[string]interface{}{"rows": [][]float64{{1, 2, 3}}}, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		var resp struct{ Error string }
+		if got := post(t, srv.URL+tt.path, tt.body, &resp); got != tt.want {
+			t.Errorf("POST %s status = %d, want %d", tt.path, got, tt.want)
+		}
+		if tt.want != http.StatusNotFound && resp.Error == "" {
+			t.Errorf("POST %s did not return error message", tt.path)
+		}
+	}
+	resp, _ := http.Get(srv.URL + "/predict")
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET /predict status = %d", resp.StatusCode)
+	}
+	resp.Body.Close()
+
+	// Distances to dropped clusters are null.
+	var distances struct{ Distances [][]*float64 }
+	post(t, srv.URL+"/distances", map[string]interface{}{"rows": [][]float64{{1, 2, 3}}}, &distances)
+	if d := distances.Distances[0]; d[0] == nil || d[2] != nil {
+		t.Errorf("POST /distances = %v", d)
+	}
+
+	for _, m := range []*Model{nil, {}, {KModes: cluster.NewKModes(cluster.HammingDistance, cluster.InitCao, 3, 1, 50, nil, "")}} {
+		if _, err := NewHandler(func() (*Model, error) { return m, nil }); err == nil {
+			t.Errorf("NewHandler(%+v) did not fail", m)
+		}
+	}
+	if _, err := NewHandler(KModesFile(filepath.Join(t.TempDir(), "missing.gob"), cluster.HammingDistance)); err == nil {
+		t.Errorf("NewHandler() of missing file did not fail")
+	}
+}
+
+func TestHandler_Reload(t *testing.T) {
+	X := testData(200)
+	path := filepath.Join(t.TempDir(), "model.gob")
+	saveKModes(t, X, 3, path)
+	h, err := NewHandler(KModesFile(path, cluster.HammingDistance))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	// Requests sent while the model is reloaded must all succeed.
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				var resp struct{ Clusters []int }
+				if status := post(t, srv.URL+"/predict/batch", map[string]interface{}{"rows": [][]float64{X.RawRowView(0), X.RawRowView(1)}}, &resp); status != http.StatusOK || len(resp.Clusters) != 2 {
+					t.Errorf("POST /predict/batch during reload status = %d", status)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		if err := h.Reload(); err != nil {
+			t.Errorf("Handler.Reload() error = %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	saveKModes(t, X, 5, path)
+	if err := h.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if md := getMetadata(t, srv.URL); md.Clusters != 5 {
+		t.Errorf("GET /metadata after reload clusters = %d, want 5", md.Clusters)
+	}
+
+	// Failed reload keeps the previous model.
+	os.Remove(path)
+	if err := h.Reload(); err == nil {
+		t.Errorf("Handler.Reload() of missing file did not fail")
+	}
+	if md := getMetadata(t, srv.URL); md.Clusters != 5 {
+		t.Errorf("GET /metadata after failed reload clusters = %d, want 5", md.Clusters)
+	}
+}
+
+// TestHandler_ReloadWeighted checks that attribute weights of a served model
+// do not change when another model is loaded, run it with -race.
+func TestHandler_ReloadWeighted(t *testing.T) {
+	X := testData(200)
+	path := filepath.Join(t.TempDir(), "model.gob")
+	fit := func(weights []float64) *cluster.KModes {
+		km := cluster.NewKModes(cluster.WeightedHammingDistance, cluster.InitCao, 3, 1, 50, [][]float64{weights}, path)
+		km.Seed = 1
+		if err := km.FitModel(X); err != nil {
+			t.Fatal(err)
+		}
+		return km
+	}
+	km := fit([]float64{1, 2, 4})
+	if err := km.SaveModel(); err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHandler(KModesFile(path, cluster.WeightedHammingDistance))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	rows := map[string]interface{}{"rows": [][]float64{X.RawRowView(0), X.RawRowView(1)}}
+	distances := func() [][]float64 {
+		var resp struct{ Distances [][]float64 }
+		if status := post(t, srv.URL+"/distances", rows, &resp); status != http.StatusOK {
+			t.Errorf("POST /distances status = %d", status)
+		}
+		return resp.Distances
+	}
+	want := distances()
+
+	// An unfitted model with other weights must not be served nor change
+	// weights of the served one.
+	unfitted := fit([]float64{9, 9, 9})
+	unfitted.IsFitted = false
+	if err := unfitted.SaveModel(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if got := distances(); !reflect.DeepEqual(got, want) {
+					t.Errorf("POST /distances during failed reload = %v, want %v", got, want)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		if err := h.Reload(); err == nil {
+			t.Errorf("Handler.Reload() of unfitted model did not fail")
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	// A new model is served with its own weights.
+	km = fit([]float64{4, 2, 1})
+	if err := km.SaveModel(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	D, err := km.Transform(cluster.NewDenseMatrix(2, 3, append(append([]float64(nil), X.RawRowView(0)...), X.RawRowView(1)...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := distances(); !reflect.DeepEqual(got, [][]float64{D.RawRowView(0), D.RawRowView(1)}) {
+		t.Errorf("POST /distances after reload = %v, want %v", got, [][]float64{D.RawRowView(0), D.RawRowView(1)})
+	}
+}
+
+func TestHandler_Watch(t *testing.T) {
+	X := testData(100)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "model.gob")
+	saveKModes(t, X, 3, path)
+	h, err := NewHandler(KModesFile(path, cluster.HammingDistance))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go h.Watch(path, 10*time.Millisecond, stop, func(err error) { t.Errorf("Handler.Watch() reload error = %v", err) })
+
+	// Let the watcher record the current file, then replace it atomically.
+	time.Sleep(50 * time.Millisecond)
+	tmp := filepath.Join(dir, "new.gob")
+	saveKModes(t, X, 6, tmp)
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if m, _ := h.current(); m.KModes.ClustersNumber == 6 {
+			return
+		}
+	}
+	t.Errorf("Handler.Watch() did not reload the model")
+}